@@ -0,0 +1,568 @@
+package copilot
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ArchiveOptions configures ArchiveSession.
+type ArchiveOptions struct {
+	// IncludeToolTelemetry includes ToolResult.ToolTelemetry payloads found
+	// in archived tool-result events. Default: false, to keep archives small.
+	IncludeToolTelemetry bool
+}
+
+// ArchiveHandle identifies an archived session within an ArchiveStore.
+type ArchiveHandle struct {
+	ID        string
+	SessionID string
+}
+
+// ArchiveManifest describes an archived session: its metadata plus enough
+// configuration to replay it elsewhere. Provider, MCPServers, and
+// CustomAgents are only populated when the archiving Client itself created
+// the session (the server has no API to retrieve them after the fact for a
+// session created elsewhere); they are nil otherwise. ReplaySession prefers
+// ReplayOptions.Provider over the recorded Provider, and passes MCPServers
+// and CustomAgents through as recorded (ReplayOptions has no such fields).
+type ArchiveManifest struct {
+	SessionID    string                     `json:"sessionId"`
+	Metadata     SessionMetadata            `json:"metadata"`
+	Model        string                     `json:"model,omitempty"`
+	Provider     *ProviderConfig            `json:"provider,omitempty"`
+	MCPServers   map[string]MCPServerConfig `json:"mcpServers,omitempty"`
+	CustomAgents []CustomAgentConfig        `json:"customAgents,omitempty"`
+	ToolNames    []string                   `json:"toolNames,omitempty"`
+	EventCount   int                        `json:"eventCount"`
+	ArchivedAt   string                     `json:"archivedAt"`
+}
+
+// SearchQuery filters SearchArchivedSessions results. Zero-value fields are
+// not applied, i.e. an empty SearchQuery matches every archive.
+type SearchQuery struct {
+	Since           time.Time
+	Until           time.Time
+	Model           string
+	ToolNames       []string
+	PromptContains  string
+	SummaryContains string
+}
+
+// SearchMatch is a SearchArchivedSessions result: an archived session's
+// metadata plus the text snippets that matched the query.
+type SearchMatch struct {
+	Handle     ArchiveHandle
+	Metadata   SessionMetadata
+	Highlights []string
+}
+
+// ReplayOptions configures ReplaySession. Zero-value fields fall back to
+// the settings recorded in the archive's manifest.
+type ReplayOptions struct {
+	Model               string
+	Provider            *ProviderConfig
+	Tools               []Tool
+	OnPermissionRequest PermissionHandler
+}
+
+// ArchiveStore persists archived sessions as opaque, store-chosen-ID blobs.
+// The Client's archive methods write and read a plain (uncompressed) NDJSON
+// stream (an ArchiveManifest line followed
+// by one SessionEvent per line) through Create/Open; implementations are free to compress, encrypt, or lay
+// out that stream however they like on the storage side. Implementations
+// must be safe for concurrent use.
+type ArchiveStore interface {
+	// Create begins a new archive for sessionID, returning a store-chosen ID
+	// and a writer for the archive stream. The caller closes the writer when
+	// done.
+	Create(sessionID string) (id string, w io.WriteCloser, err error)
+	// Open returns a reader for a previously created archive's stream.
+	Open(id string) (io.ReadCloser, error)
+	// List returns the IDs of every archive the store holds.
+	List() ([]string, error)
+	// Delete removes an archive. Deleting a nonexistent ID is not an error.
+	Delete(id string) error
+}
+
+// FilesystemArchiveStore is the default ArchiveStore, storing each archive
+// as a gzip'd NDJSON file under Dir.
+type FilesystemArchiveStore struct {
+	Dir string
+
+	seq int64
+}
+
+func (s *FilesystemArchiveStore) path(id string) string {
+	return filepath.Join(s.Dir, id+".ndjson.gz")
+}
+
+func (s *FilesystemArchiveStore) Create(sessionID string) (string, io.WriteCloser, error) {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return "", nil, fmt.Errorf("copilot: creating archive dir: %w", err)
+	}
+
+	for {
+		id := sessionID + "-" + strconv.FormatInt(atomic.AddInt64(&s.seq, 1), 10)
+		f, err := os.OpenFile(s.path(id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+		if os.IsExist(err) {
+			// Another store over the same Dir already used this ID; take the
+			// next one rather than overwrite that archive.
+			continue
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("copilot: creating archive file: %w", err)
+		}
+		return id, &gzipWriteCloser{gz: gzip.NewWriter(f), f: f}, nil
+	}
+}
+
+func (s *FilesystemArchiveStore) Open(id string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(id))
+	if err != nil {
+		return nil, fmt.Errorf("copilot: opening archive file: %w", err)
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("copilot: reading archive file: %w", err)
+	}
+	return &gzipReadCloser{gz: gz, f: f}, nil
+}
+
+func (s *FilesystemArchiveStore) List() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("copilot: listing archive dir: %w", err)
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if name, ok := strings.CutSuffix(e.Name(), ".ndjson.gz"); ok {
+			ids = append(ids, name)
+		}
+	}
+	return ids, nil
+}
+
+func (s *FilesystemArchiveStore) Delete(id string) error {
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("copilot: deleting archive file: %w", err)
+	}
+	return nil
+}
+
+// gzipWriteCloser closes both the gzip stream and the underlying file.
+type gzipWriteCloser struct {
+	gz *gzip.Writer
+	f  *os.File
+}
+
+func (g *gzipWriteCloser) Write(p []byte) (int, error) { return g.gz.Write(p) }
+
+func (g *gzipWriteCloser) Close() error {
+	if err := g.gz.Close(); err != nil {
+		g.f.Close()
+		return err
+	}
+	return g.f.Close()
+}
+
+// gzipReadCloser closes both the gzip stream and the underlying file.
+type gzipReadCloser struct {
+	gz *gzip.Reader
+	f  *os.File
+}
+
+func (g *gzipReadCloser) Read(p []byte) (int, error) { return g.gz.Read(p) }
+
+func (g *gzipReadCloser) Close() error {
+	gzErr := g.gz.Close()
+	fErr := g.f.Close()
+	if gzErr != nil {
+		return gzErr
+	}
+	return fErr
+}
+
+// archiveStore returns the client's configured ArchiveStore, defaulting to a
+// FilesystemArchiveStore rooted at ArchiveDir (or Cwd/"copilot-archives").
+func (c *Client) archiveStore() ArchiveStore {
+	if c.options.ArchiveStore != nil {
+		return c.options.ArchiveStore
+	}
+	dir := c.options.ArchiveDir
+	if dir == "" {
+		dir = filepath.Join(c.options.Cwd, "copilot-archives")
+	}
+	return &FilesystemArchiveStore{Dir: dir}
+}
+
+// ArchiveSession snapshots a session's full event stream, tool invocations,
+// permission decisions, and metadata into the configured ArchiveStore.
+func (c *Client) ArchiveSession(sessionID string, opts ArchiveOptions) (ArchiveHandle, error) {
+	if err := c.ensureStarted(); err != nil {
+		return ArchiveHandle{}, err
+	}
+
+	sessions, err := c.ListSessions()
+	if err != nil {
+		return ArchiveHandle{}, err
+	}
+	var metadata SessionMetadata
+	found := false
+	for _, m := range sessions.Sessions {
+		if m.SessionID == sessionID {
+			metadata = m
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ArchiveHandle{}, fmt.Errorf("copilot: session %q not found", sessionID)
+	}
+
+	session := &Session{SessionID: sessionID, client: c}
+	events, err := session.GetMessages()
+	if err != nil {
+		return ArchiveHandle{}, err
+	}
+	if !opts.IncludeToolTelemetry {
+		events = stripToolTelemetry(events)
+	}
+
+	manifest := ArchiveManifest{
+		SessionID:  sessionID,
+		Metadata:   metadata,
+		ToolNames:  toolNamesInvoked(events),
+		EventCount: len(events),
+		ArchivedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if model, ok := firstString(events, "model"); ok {
+		manifest.Model = model
+	}
+	// config is only known when this Client created the session itself
+	// (CreateSession records it); a session created elsewhere, or one
+	// created before this process started, archives without it; ReplaySession
+	// then uses ReplayOptions.Provider and recreates the session without MCP
+	// servers or custom agents.
+	if config := c.sessionConfig(sessionID); config != nil {
+		if config.Model != "" {
+			manifest.Model = config.Model
+		}
+		manifest.Provider = config.Provider
+		manifest.MCPServers = config.MCPServers
+		manifest.CustomAgents = config.CustomAgents
+	}
+
+	store := c.archiveStore()
+	id, w, err := store.Create(sessionID)
+	if err != nil {
+		return ArchiveHandle{}, err
+	}
+	defer w.Close()
+
+	if err := encodeArchiveStream(w, manifest, events); err != nil {
+		return ArchiveHandle{}, err
+	}
+	return ArchiveHandle{ID: id, SessionID: sessionID}, nil
+}
+
+// SearchArchivedSessions returns metadata and match highlights for every
+// archived session matching query.
+func (c *Client) SearchArchivedSessions(query SearchQuery) ([]SearchMatch, error) {
+	store := c.archiveStore()
+	ids, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []SearchMatch
+	for _, id := range ids {
+		r, err := store.Open(id)
+		if err != nil {
+			return nil, err
+		}
+		manifest, events, err := decodeArchiveStream(r)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("copilot: reading archive %q: %w", id, err)
+		}
+
+		if highlights, ok := matchesQuery(manifest, events, query); ok {
+			matches = append(matches, SearchMatch{
+				Handle:     ArchiveHandle{ID: id, SessionID: manifest.SessionID},
+				Metadata:   manifest.Metadata,
+				Highlights: highlights,
+			})
+		}
+	}
+	return matches, nil
+}
+
+// ExportArchive writes a portable gzip'd NDJSON copy of an archive to w, for
+// moving it to another machine.
+func (c *Client) ExportArchive(handle ArchiveHandle, w io.Writer) error {
+	r, err := c.archiveStore().Open(handle.ID)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	gz := gzip.NewWriter(w)
+	if _, err := io.Copy(gz, r); err != nil {
+		gz.Close()
+		return fmt.Errorf("copilot: exporting archive: %w", err)
+	}
+	return gz.Close()
+}
+
+// ImportArchive reads a gzip'd NDJSON archive produced by ExportArchive and
+// stores it in the configured ArchiveStore, returning the imported session's
+// metadata.
+func (c *Client) ImportArchive(r io.Reader) (SessionMetadata, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return SessionMetadata{}, fmt.Errorf("copilot: reading archive: %w", err)
+	}
+	defer gz.Close()
+
+	manifest, events, err := decodeArchiveStream(gz)
+	if err != nil {
+		return SessionMetadata{}, fmt.Errorf("copilot: reading archive: %w", err)
+	}
+
+	store := c.archiveStore()
+	_, w, err := store.Create(manifest.SessionID)
+	if err != nil {
+		return SessionMetadata{}, err
+	}
+	defer w.Close()
+
+	if err := encodeArchiveStream(w, manifest, events); err != nil {
+		return SessionMetadata{}, err
+	}
+	return manifest.Metadata, nil
+}
+
+// ReplaySession recreates a fresh session from an archive and re-sends its
+// archived user messages against opts.Model/opts.Provider (or, if unset, the
+// model/provider recorded in the archive's manifest).
+func (c *Client) ReplaySession(handle ArchiveHandle, opts ReplayOptions) (*Session, error) {
+	r, err := c.archiveStore().Open(handle.ID)
+	if err != nil {
+		return nil, err
+	}
+	manifest, events, err := decodeArchiveStream(r)
+	r.Close()
+	if err != nil {
+		return nil, fmt.Errorf("copilot: reading archive %q: %w", handle.ID, err)
+	}
+
+	model := opts.Model
+	if model == "" {
+		model = manifest.Model
+	}
+	provider := opts.Provider
+	if provider == nil {
+		provider = manifest.Provider
+	}
+
+	session, err := c.CreateSession(&SessionConfig{
+		Model:               model,
+		Provider:            provider,
+		Tools:               opts.Tools,
+		OnPermissionRequest: opts.OnPermissionRequest,
+		MCPServers:          manifest.MCPServers,
+		CustomAgents:        manifest.CustomAgents,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ev := range events {
+		if ev.Kind != "user.message" {
+			continue
+		}
+		prompt, _ := ev.Data["prompt"].(string)
+		if prompt == "" {
+			continue
+		}
+		if _, err := session.SendMessage(MessageOptions{Prompt: prompt}); err != nil {
+			return session, fmt.Errorf("copilot: replaying message: %w", err)
+		}
+	}
+	return session, nil
+}
+
+// encodeArchiveStream writes manifest followed by events to w as NDJSON: one
+// JSON object per line, manifest first.
+func encodeArchiveStream(w io.Writer, manifest ArchiveManifest, events []SessionEvent) error {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(manifest); err != nil {
+		return fmt.Errorf("copilot: encoding archive manifest: %w", err)
+	}
+	for _, ev := range events {
+		if err := enc.Encode(ev); err != nil {
+			return fmt.Errorf("copilot: encoding archived event: %w", err)
+		}
+	}
+	return nil
+}
+
+// decodeArchiveStream reads an NDJSON stream written by encodeArchiveStream:
+// a manifest line followed by zero or more event lines.
+func decodeArchiveStream(r io.Reader) (ArchiveManifest, []SessionEvent, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return ArchiveManifest{}, nil, err
+		}
+		return ArchiveManifest{}, nil, fmt.Errorf("copilot: archive is empty")
+	}
+	var manifest ArchiveManifest
+	if err := json.Unmarshal(scanner.Bytes(), &manifest); err != nil {
+		return ArchiveManifest{}, nil, fmt.Errorf("copilot: decoding archive manifest: %w", err)
+	}
+
+	var events []SessionEvent
+	for scanner.Scan() {
+		var ev SessionEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			return ArchiveManifest{}, nil, fmt.Errorf("copilot: decoding archived event: %w", err)
+		}
+		events = append(events, ev)
+	}
+	if err := scanner.Err(); err != nil {
+		return ArchiveManifest{}, nil, err
+	}
+	return manifest, events, nil
+}
+
+// matchesQuery reports whether an archived session satisfies query, along
+// with the text snippets that matched.
+func matchesQuery(manifest ArchiveManifest, events []SessionEvent, query SearchQuery) ([]string, bool) {
+	if !query.Since.IsZero() || !query.Until.IsZero() {
+		start, err := time.Parse(time.RFC3339, manifest.Metadata.StartTime)
+		if err != nil {
+			return nil, false
+		}
+		if !query.Since.IsZero() && start.Before(query.Since) {
+			return nil, false
+		}
+		if !query.Until.IsZero() && start.After(query.Until) {
+			return nil, false
+		}
+	}
+
+	if query.Model != "" && manifest.Model != query.Model {
+		return nil, false
+	}
+
+	for _, want := range query.ToolNames {
+		if !containsString(manifest.ToolNames, want) {
+			return nil, false
+		}
+	}
+
+	var highlights []string
+	if query.SummaryContains != "" {
+		if manifest.Metadata.Summary == nil || !strings.Contains(*manifest.Metadata.Summary, query.SummaryContains) {
+			return nil, false
+		}
+		highlights = append(highlights, *manifest.Metadata.Summary)
+	}
+
+	if query.PromptContains != "" {
+		matched := false
+		for _, ev := range events {
+			if ev.Kind != "user.message" {
+				continue
+			}
+			prompt, _ := ev.Data["prompt"].(string)
+			if strings.Contains(prompt, query.PromptContains) {
+				matched = true
+				highlights = append(highlights, prompt)
+			}
+		}
+		if !matched {
+			return nil, false
+		}
+	}
+
+	return highlights, true
+}
+
+// toolNamesInvoked returns the sorted, de-duplicated set of tool names
+// invoked across a session's event stream.
+func toolNamesInvoked(events []SessionEvent) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, ev := range events {
+		if ev.Kind != "tool.call" {
+			continue
+		}
+		name, _ := ev.Data["toolName"].(string)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stripToolTelemetry removes the toolTelemetry field from tool-result event
+// data so it isn't persisted in the archive.
+func stripToolTelemetry(events []SessionEvent) []SessionEvent {
+	out := make([]SessionEvent, len(events))
+	for i, ev := range events {
+		if ev.Kind == "tool.result" && ev.Data["toolTelemetry"] != nil {
+			data := make(map[string]interface{}, len(ev.Data))
+			for k, v := range ev.Data {
+				if k != "toolTelemetry" {
+					data[k] = v
+				}
+			}
+			ev.Data = data
+		}
+		out[i] = ev
+	}
+	return out
+}
+
+func firstString(events []SessionEvent, key string) (string, bool) {
+	for _, ev := range events {
+		if v, ok := ev.Data[key].(string); ok && v != "" {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func containsString(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
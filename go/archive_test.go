@@ -0,0 +1,364 @@
+package copilot
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestToolNamesInvoked(t *testing.T) {
+	events := []SessionEvent{
+		{Kind: "tool.call", Data: map[string]interface{}{"toolName": "run_tests"}},
+		{Kind: "tool.call", Data: map[string]interface{}{"toolName": "grep"}},
+		{Kind: "tool.call", Data: map[string]interface{}{"toolName": "grep"}},
+		{Kind: "tool.call", Data: map[string]interface{}{"toolName": "apply_patch"}},
+	}
+
+	got := toolNamesInvoked(events)
+	want := []string{"apply_patch", "grep", "run_tests"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected sorted, de-duplicated %v, got %v", want, got)
+			break
+		}
+	}
+}
+
+func TestFilesystemArchiveStore(t *testing.T) {
+	t.Run("round trips Create/Open/List/Delete", func(t *testing.T) {
+		store := &FilesystemArchiveStore{Dir: t.TempDir()}
+
+		id, w, err := store.Create("sess-1")
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if _, err := w.Write([]byte("hello archive")); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		ids, err := store.List()
+		if err != nil || len(ids) != 1 || ids[0] != id {
+			t.Fatalf("Expected List to return [%s], got %v (err: %v)", id, ids, err)
+		}
+
+		r, err := store.Open(id)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		var buf bytes.Buffer
+		buf.ReadFrom(r)
+		r.Close()
+		if buf.String() != "hello archive" {
+			t.Errorf("Expected %q, got %q", "hello archive", buf.String())
+		}
+
+		if err := store.Delete(id); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		ids, _ = store.List()
+		if len(ids) != 0 {
+			t.Errorf("Expected no archives after Delete, got %v", ids)
+		}
+	})
+
+	t.Run("List on a missing directory returns no error", func(t *testing.T) {
+		store := &FilesystemArchiveStore{Dir: t.TempDir() + "/does-not-exist"}
+		ids, err := store.List()
+		if err != nil || ids != nil {
+			t.Errorf("Expected (nil, nil), got (%v, %v)", ids, err)
+		}
+	})
+}
+
+// archiveRPCHandler serves session.list and session.getMessages for a single
+// fixed session, for exercising ArchiveSession and SearchArchivedSessions.
+func archiveRPCHandler(metadata SessionMetadata, events []SessionEvent) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req jsonrpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var result interface{}
+		switch req.Method {
+		case "session.list":
+			result = ListSessionsResponse{Sessions: []SessionMetadata{metadata}}
+		case "session.getMessages":
+			result = SessionGetMessagesResponse{Events: events}
+		default:
+			http.Error(w, "unexpected method "+req.Method, http.StatusBadRequest)
+			return
+		}
+
+		resultJSON, _ := json.Marshal(result)
+		resp := jsonrpcResponse{JSONRPC: "2.0", ID: req.ID, Result: resultJSON}
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func testArchiveFixture() (SessionMetadata, []SessionEvent) {
+	summary := "debugging the flaky retry test"
+	metadata := SessionMetadata{
+		SessionID:    "sess-archive",
+		StartTime:    "2026-01-15T10:00:00Z",
+		ModifiedTime: "2026-01-15T10:05:00Z",
+		Summary:      &summary,
+	}
+	events := []SessionEvent{
+		{Kind: "user.message", SessionID: metadata.SessionID, Data: map[string]interface{}{"prompt": "why is the retry test flaky?", "model": "gpt-5"}},
+		{Kind: "tool.call", SessionID: metadata.SessionID, Data: map[string]interface{}{"toolName": "run_tests"}},
+		{Kind: "tool.result", SessionID: metadata.SessionID, Data: map[string]interface{}{"toolName": "run_tests", "toolTelemetry": map[string]interface{}{"durationMs": 42}}},
+	}
+	return metadata, events
+}
+
+func TestClient_ArchiveSessionAndSearch(t *testing.T) {
+	metadata, events := testArchiveFixture()
+	server := httptest.NewServer(archiveRPCHandler(metadata, events))
+	defer server.Close()
+
+	client := NewClient(&ClientOptions{CLIUrl: server.URL, ArchiveDir: t.TempDir()})
+	t.Cleanup(client.ForceStop)
+
+	t.Run("ArchiveSession snapshots the event stream and strips tool telemetry by default", func(t *testing.T) {
+		handle, err := client.ArchiveSession(metadata.SessionID, ArchiveOptions{})
+		if err != nil {
+			t.Fatalf("ArchiveSession failed: %v", err)
+		}
+		if handle.SessionID != metadata.SessionID {
+			t.Errorf("Expected handle.SessionID %s, got %s", metadata.SessionID, handle.SessionID)
+		}
+
+		r, err := client.archiveStore().Open(handle.ID)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		defer r.Close()
+		manifest, gotEvents, err := decodeArchiveStream(r)
+		if err != nil {
+			t.Fatalf("decodeArchiveStream failed: %v", err)
+		}
+		if manifest.Model != "gpt-5" {
+			t.Errorf("Expected manifest.Model gpt-5, got %s", manifest.Model)
+		}
+		if len(manifest.ToolNames) != 1 || manifest.ToolNames[0] != "run_tests" {
+			t.Errorf("Expected ToolNames [run_tests], got %v", manifest.ToolNames)
+		}
+		for _, ev := range gotEvents {
+			if ev.Kind == "tool.result" && ev.Data["toolTelemetry"] != nil {
+				t.Error("Expected toolTelemetry to be stripped")
+			}
+		}
+	})
+
+	t.Run("SearchArchivedSessions filters by model, tool name, and prompt substring", func(t *testing.T) {
+		if _, err := client.ArchiveSession(metadata.SessionID, ArchiveOptions{}); err != nil {
+			t.Fatalf("ArchiveSession failed: %v", err)
+		}
+
+		matches, err := client.SearchArchivedSessions(SearchQuery{
+			Model:          "gpt-5",
+			ToolNames:      []string{"run_tests"},
+			PromptContains: "flaky",
+		})
+		if err != nil {
+			t.Fatalf("SearchArchivedSessions failed: %v", err)
+		}
+		if len(matches) == 0 {
+			t.Fatal("Expected at least one match")
+		}
+		for _, m := range matches {
+			if m.Metadata.SessionID != metadata.SessionID {
+				t.Errorf("Expected SessionID %s, got %s", metadata.SessionID, m.Metadata.SessionID)
+			}
+		}
+
+		noMatches, err := client.SearchArchivedSessions(SearchQuery{Model: "claude-opus"})
+		if err != nil {
+			t.Fatalf("SearchArchivedSessions failed: %v", err)
+		}
+		if len(noMatches) != 0 {
+			t.Errorf("Expected no matches for a different model, got %d", len(noMatches))
+		}
+	})
+
+	t.Run("SearchArchivedSessions filters by time range", func(t *testing.T) {
+		since, _ := time.Parse(time.RFC3339, "2026-01-16T00:00:00Z")
+		matches, err := client.SearchArchivedSessions(SearchQuery{Since: since})
+		if err != nil {
+			t.Fatalf("SearchArchivedSessions failed: %v", err)
+		}
+		if len(matches) != 0 {
+			t.Errorf("Expected no matches for a Since after the session started, got %d", len(matches))
+		}
+	})
+}
+
+func TestClient_ArchiveSessionCapturesCreatingSessionConfig(t *testing.T) {
+	// session.list/getMessages only ever return server-side metadata, which
+	// doesn't carry provider/MCP/custom-agent config; ArchiveSession can only
+	// snapshot those for a session this same Client created.
+	sessionID := "sess-with-config"
+	metadata := SessionMetadata{SessionID: sessionID, StartTime: "2026-01-15T10:00:00Z"}
+	events := []SessionEvent{
+		{Kind: "user.message", SessionID: sessionID, Data: map[string]interface{}{"prompt": "hi"}},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req jsonrpcRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		var result interface{}
+		switch req.Method {
+		case "session.create":
+			result = SessionCreateResponse{SessionID: sessionID}
+		case "session.list":
+			result = ListSessionsResponse{Sessions: []SessionMetadata{metadata}}
+		case "session.getMessages":
+			result = SessionGetMessagesResponse{Events: events}
+		}
+		resultJSON, _ := json.Marshal(result)
+		resp := jsonrpcResponse{JSONRPC: "2.0", ID: req.ID, Result: resultJSON}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(&ClientOptions{CLIUrl: server.URL, ArchiveDir: t.TempDir()})
+	t.Cleanup(client.ForceStop)
+
+	provider := &ProviderConfig{Type: "anthropic", BaseURL: "https://example.com"}
+	mcpServers := map[string]MCPServerConfig{"fs": {"type": "local"}}
+	customAgents := []CustomAgentConfig{{Name: "reviewer", Prompt: "review diffs"}}
+	if _, err := client.CreateSession(&SessionConfig{
+		Model:        "gpt-5",
+		Provider:     provider,
+		MCPServers:   mcpServers,
+		CustomAgents: customAgents,
+	}); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	handle, err := client.ArchiveSession(sessionID, ArchiveOptions{})
+	if err != nil {
+		t.Fatalf("ArchiveSession failed: %v", err)
+	}
+
+	r, err := client.archiveStore().Open(handle.ID)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer r.Close()
+	manifest, _, err := decodeArchiveStream(r)
+	if err != nil {
+		t.Fatalf("decodeArchiveStream failed: %v", err)
+	}
+
+	if manifest.Model != "gpt-5" {
+		t.Errorf("Expected manifest.Model gpt-5, got %s", manifest.Model)
+	}
+	if manifest.Provider == nil || manifest.Provider.BaseURL != provider.BaseURL {
+		t.Errorf("Expected manifest.Provider %v, got %v", provider, manifest.Provider)
+	}
+	if len(manifest.MCPServers) != 1 {
+		t.Errorf("Expected manifest.MCPServers to carry the session's MCP config, got %v", manifest.MCPServers)
+	}
+	if len(manifest.CustomAgents) != 1 || manifest.CustomAgents[0].Name != "reviewer" {
+		t.Errorf("Expected manifest.CustomAgents to carry the session's agents, got %v", manifest.CustomAgents)
+	}
+}
+
+func TestExportImportArchive(t *testing.T) {
+	metadata, events := testArchiveFixture()
+	server := httptest.NewServer(archiveRPCHandler(metadata, events))
+	defer server.Close()
+
+	source := NewClient(&ClientOptions{CLIUrl: server.URL, ArchiveDir: t.TempDir()})
+	t.Cleanup(source.ForceStop)
+	handle, err := source.ArchiveSession(metadata.SessionID, ArchiveOptions{})
+	if err != nil {
+		t.Fatalf("ArchiveSession failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := source.ExportArchive(handle, &buf); err != nil {
+		t.Fatalf("ExportArchive failed: %v", err)
+	}
+
+	dest := NewClient(&ClientOptions{CLIUrl: server.URL, ArchiveDir: t.TempDir()})
+	t.Cleanup(dest.ForceStop)
+	imported, err := dest.ImportArchive(&buf)
+	if err != nil {
+		t.Fatalf("ImportArchive failed: %v", err)
+	}
+	if imported.SessionID != metadata.SessionID {
+		t.Errorf("Expected imported SessionID %s, got %s", metadata.SessionID, imported.SessionID)
+	}
+
+	matches, err := dest.SearchArchivedSessions(SearchQuery{})
+	if err != nil {
+		t.Fatalf("SearchArchivedSessions failed: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("Expected the imported archive to be searchable, got %d matches", len(matches))
+	}
+}
+
+func TestClient_ReplaySession(t *testing.T) {
+	metadata, events := testArchiveFixture()
+	archiveServer := httptest.NewServer(archiveRPCHandler(metadata, events))
+	defer archiveServer.Close()
+
+	archiveDir := t.TempDir()
+	source := NewClient(&ClientOptions{CLIUrl: archiveServer.URL, ArchiveDir: archiveDir})
+	t.Cleanup(source.ForceStop)
+	handle, err := source.ArchiveSession(metadata.SessionID, ArchiveOptions{})
+	if err != nil {
+		t.Fatalf("ArchiveSession failed: %v", err)
+	}
+
+	var gotPrompts []string
+	replayServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req jsonrpcRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		var result interface{}
+		switch req.Method {
+		case "session.create":
+			result = SessionCreateResponse{SessionID: "sess-replay"}
+		case "session.send":
+			params, _ := req.Params.(map[string]interface{})
+			if prompt, ok := params["prompt"].(string); ok {
+				gotPrompts = append(gotPrompts, prompt)
+			}
+			result = SessionSendResponse{MessageID: "msg-1"}
+		}
+		resultJSON, _ := json.Marshal(result)
+		resp := jsonrpcResponse{JSONRPC: "2.0", ID: req.ID, Result: resultJSON}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer replayServer.Close()
+
+	replayClient := NewClient(&ClientOptions{CLIUrl: replayServer.URL, ArchiveDir: archiveDir})
+	t.Cleanup(replayClient.ForceStop)
+
+	session, err := replayClient.ReplaySession(handle, ReplayOptions{})
+	if err != nil {
+		t.Fatalf("ReplaySession failed: %v", err)
+	}
+	if session.SessionID != "sess-replay" {
+		t.Errorf("Expected sessionId sess-replay, got %s", session.SessionID)
+	}
+	if len(gotPrompts) != 1 || gotPrompts[0] != "why is the retry test flaky?" {
+		t.Errorf("Expected the archived user prompt to be replayed, got %v", gotPrompts)
+	}
+}
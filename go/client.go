@@ -0,0 +1,517 @@
+package copilot
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Client is a connection to an existing Copilot CLI server reached over
+// CLIUrl. Without CLIUrl, connecting fails: this build does not spawn and
+// manage the CLI process itself (see ensureStarted).
+type Client struct {
+	options *ClientOptions
+
+	// actualHost and actualPort are populated when CLIUrl resolves to a
+	// TCP/HTTP(S) endpoint.
+	actualHost string
+	actualPort int
+	// actualSocket is populated when CLIUrl resolves to a Unix domain socket.
+	actualSocket string
+	// actualScheme is "tcp", "http", or "https", describing how actualHost
+	// and actualPort should be dialed. Unused in Unix socket mode.
+	actualScheme string
+	// isExternalServer is true when the client connects to a server it did
+	// not spawn (i.e. CLIUrl was provided).
+	isExternalServer bool
+
+	mu             sync.Mutex
+	conn           rpcClient
+	cmd            *exec.Cmd
+	sessionTools   map[string]map[string]Tool
+	sessionConfigs map[string]*SessionConfig
+
+	// credential resolves the auth token for a client-managed CLI process;
+	// nil for external-server clients (CLIUrl), which authenticate out of
+	// band.
+	credential  Credential
+	cachedToken Token
+
+	// supervisor watches and reconnects the connection to an external
+	// server; nil unless ClientOptions.Reconnect was set.
+	supervisor *supervisor
+}
+
+// NewClient creates a Client for the given options. It panics on invalid
+// configuration; use NewClientE to handle configuration errors yourself.
+func NewClient(options *ClientOptions) *Client {
+	client, err := NewClientE(options)
+	if err != nil {
+		panic(err.Error())
+	}
+	return client
+}
+
+// NewClientE creates a Client for the given options, returning a
+// *ConfigError (wrapping one of ErrInvalidCLIUrl, ErrInvalidPort,
+// ErrConflictingOptions, or ErrAuthWithExternalServer) instead of panicking
+// when options are invalid.
+func NewClientE(options *ClientOptions) (*Client, error) {
+	client, err := buildClient(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if client.isExternalServer && client.options.Reconnect != nil {
+		// Built before the initial dial so the first connection, not just
+		// ones (re)dialed later by the supervisor's own reconnectLoop, gets
+		// attachRequeueSink'd and can requeue calls across its first drop.
+		client.supervisor = newSupervisor(client, client.options.Reconnect)
+		if err := client.ensureStarted(); err != nil {
+			client.supervisor = nil
+			return nil, err
+		}
+		go client.supervisor.run()
+	}
+
+	return client, nil
+}
+
+// buildClient validates options and constructs the resulting Client without
+// any I/O: no dialing, no spawned goroutines. It is NewClientE's pure core,
+// shared with ValidateOptions so validating a config never opens a
+// connection or leaks a supervisor goroutine.
+func buildClient(options *ClientOptions) (*Client, error) {
+	if options == nil {
+		options = &ClientOptions{}
+	}
+
+	client := newClientUnchecked(options)
+
+	if options.CLIUrl != "" {
+		if options.UseStdio || options.CLIPath != "" {
+			return nil, &ConfigError{
+				Field:  "CLIUrl",
+				Reason: "CLIUrl is mutually exclusive with CLIPath and UseStdio",
+				Err:    ErrConflictingOptions,
+			}
+		}
+		if options.GithubToken != "" || options.UseLoggedInUser != nil || options.Credential != nil {
+			return nil, &ConfigError{
+				Field:  "CLIUrl",
+				Reason: "GithubToken and UseLoggedInUser cannot be used with CLIUrl",
+				Err:    ErrAuthWithExternalServer,
+			}
+		}
+
+		host, port, socket, scheme, err := parseCLIUrl(options.CLIUrl)
+		if err != nil {
+			return nil, err
+		}
+
+		client.actualHost = host
+		client.actualPort = port
+		client.actualSocket = socket
+		client.actualScheme = scheme
+		client.isExternalServer = true
+		client.options.UseStdio = false
+	} else {
+		client.credential = options.Credential
+		if client.credential == nil {
+			client.credential = defaultCredentialChain(options)
+		}
+	}
+
+	return client, nil
+}
+
+// ValidateOptions checks ClientOptions for the same configuration errors
+// NewClientE would return, without constructing a Client or performing any
+// I/O (even when Reconnect is set). Useful for pre-checking config from HTTP
+// handlers or CLIs ahead of time.
+func ValidateOptions(options *ClientOptions) error {
+	_, err := buildClient(options)
+	return err
+}
+
+func newClientUnchecked(options *ClientOptions) *Client {
+	optsCopy := *options
+	return &Client{
+		options:        &optsCopy,
+		sessionTools:   make(map[string]map[string]Tool),
+		sessionConfigs: make(map[string]*SessionConfig),
+	}
+}
+
+// parseCLIUrl resolves a CLIUrl value into either a host/port pair with its
+// dialing scheme ("tcp", "http", or "https") or a Unix domain socket path
+// (unix://, unix:).
+func parseCLIUrl(raw string) (host string, port int, socket string, scheme string, err error) {
+	scheme = "tcp"
+	switch {
+	case strings.HasPrefix(raw, "unix://"):
+		socket = strings.TrimPrefix(raw, "unix://")
+		if socket == "" {
+			return "", 0, "", "", invalidCLIUrlErr(fmt.Sprintf("missing socket path in %q", raw))
+		}
+	case strings.HasPrefix(raw, "unix:"):
+		socket = strings.TrimPrefix(raw, "unix:")
+		if socket == "" {
+			return "", 0, "", "", invalidCLIUrlErr(fmt.Sprintf("missing socket path in %q", raw))
+		}
+	case strings.HasPrefix(raw, "http://"), strings.HasPrefix(raw, "https://"):
+		u, perr := url.Parse(raw)
+		if perr != nil || u.Hostname() == "" {
+			return "", 0, "", "", invalidCLIUrlErr(fmt.Sprintf("%q", raw))
+		}
+		scheme = u.Scheme
+		host = u.Hostname()
+		portStr := u.Port()
+		if portStr == "" {
+			if u.Scheme == "https" {
+				portStr = "443"
+			} else {
+				portStr = "80"
+			}
+		}
+		port, err = strconv.Atoi(portStr)
+		if err != nil {
+			return "", 0, "", "", invalidPortErr(fmt.Sprintf("%q", raw))
+		}
+	case isAllDigits(raw):
+		host = "localhost"
+		if port, err = strconv.Atoi(raw); err != nil {
+			return "", 0, "", "", invalidPortErr(fmt.Sprintf("%q", raw))
+		}
+	default:
+		h, p, serr := splitHostPort(raw)
+		if serr != nil {
+			return "", 0, "", "", invalidCLIUrlErr(fmt.Sprintf("%q", raw))
+		}
+		if port, err = strconv.Atoi(p); err != nil {
+			return "", 0, "", "", invalidPortErr(fmt.Sprintf("%q", raw))
+		}
+		host = h
+	}
+
+	if socket != "" {
+		return "", 0, socket, "", nil
+	}
+	if port < 1 || port > 65535 {
+		return "", 0, "", "", invalidPortErr(fmt.Sprintf("%q", raw))
+	}
+	return host, port, "", scheme, nil
+}
+
+func invalidCLIUrlErr(detail string) error {
+	return &ConfigError{
+		Field:  "CLIUrl",
+		Reason: fmt.Sprintf("Invalid CLIUrl format: %s", detail),
+		Err:    ErrInvalidCLIUrl,
+	}
+}
+
+func invalidPortErr(detail string) error {
+	return &ConfigError{
+		Field:  "CLIUrl",
+		Reason: fmt.Sprintf("Invalid port in CLIUrl: %s", detail),
+		Err:    ErrInvalidPort,
+	}
+}
+
+// buildTLSConfig resolves the effective TLS configuration for an https://
+// CLIUrl from TLSConfig, RootCAs, and ClientCertificates. Returns nil when
+// none of them are set, so the transport falls back to Go's defaults.
+func buildTLSConfig(options *ClientOptions) *tls.Config {
+	if options.TLSConfig != nil {
+		cfg := options.TLSConfig.Clone()
+		if cfg.RootCAs == nil {
+			cfg.RootCAs = options.RootCAs
+		}
+		if len(cfg.Certificates) == 0 {
+			cfg.Certificates = options.ClientCertificates
+		}
+		return cfg
+	}
+	if options.RootCAs == nil && len(options.ClientCertificates) == 0 {
+		return nil
+	}
+	return &tls.Config{RootCAs: options.RootCAs, Certificates: options.ClientCertificates}
+}
+
+func isAllDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func splitHostPort(raw string) (string, string, error) {
+	idx := strings.LastIndex(raw, ":")
+	if idx < 0 || idx == len(raw)-1 {
+		return "", "", fmt.Errorf("no port in address")
+	}
+	return raw[:idx], raw[idx+1:], nil
+}
+
+// ForceStop terminates the managed CLI process (if any) and closes the
+// connection to the server.
+func (c *Client) ForceStop() {
+	c.mu.Lock()
+	sup := c.supervisor
+	c.supervisor = nil
+	c.mu.Unlock()
+
+	if sup != nil {
+		sup.Stop()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+	c.cmd = nil
+}
+
+// ensureStarted lazily establishes the connection to an external CLI server.
+// For a client that owns its lifecycle (no CLIUrl) it resolves the
+// credential and then returns an error: this build does not spawn the CLI
+// process.
+func (c *Client) ensureStarted() error {
+	c.mu.Lock()
+	if c.conn != nil {
+		c.mu.Unlock()
+		return nil
+	}
+	external := c.isExternalServer
+	c.mu.Unlock()
+
+	if external {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.conn != nil {
+			return nil
+		}
+		return c.dialExternalLocked()
+	}
+
+	if _, err := c.resolveToken(context.Background()); err != nil {
+		return fmt.Errorf("copilot: resolving credential: %w", err)
+	}
+
+	return fmt.Errorf("spawning the managed CLI process is not implemented in this build")
+}
+
+// activeConn returns the client's current connection, read under c.mu since
+// the reconnect supervisor closes and replaces c.conn from another
+// goroutine (reconnect.go's reconnectLoop). Returns an error instead of a
+// nil conn during the window a reconnect is in progress.
+func (c *Client) activeConn() (rpcClient, error) {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return nil, fmt.Errorf("copilot: not connected")
+	}
+	return conn, nil
+}
+
+// CreateSession starts a new Copilot session on the connected server.
+func (c *Client) CreateSession(config *SessionConfig) (*Session, error) {
+	if config == nil {
+		config = &SessionConfig{}
+	}
+	if err := c.ensureStarted(); err != nil {
+		return nil, err
+	}
+	conn, err := c.activeConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var resp SessionCreateResponse
+	if err := conn.call("session.create", sessionCreateParams(config), &resp); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.sessionTools[resp.SessionID] = toolMap(config.Tools)
+	c.sessionConfigs[resp.SessionID] = config
+	c.mu.Unlock()
+
+	return &Session{SessionID: resp.SessionID, client: c}, nil
+}
+
+// sessionConfig returns the SessionConfig a still-known session was created
+// with, e.g. for ArchiveSession to snapshot its model/provider/MCP/agent
+// config. Returns nil for a session this Client didn't create (or has
+// forgotten via DeleteSession).
+func (c *Client) sessionConfig(sessionID string) *SessionConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessionConfigs[sessionID]
+}
+
+// sessionCreateParams strips the SDK-local, non-serializable parts of a
+// SessionConfig (Tools and OnPermissionRequest are handled by the client,
+// not sent to the server) down to the fields the server expects over the
+// wire.
+func sessionCreateParams(config *SessionConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"sessionId":        config.SessionID,
+		"model":            config.Model,
+		"configDir":        config.ConfigDir,
+		"systemMessage":    config.SystemMessage,
+		"availableTools":   config.AvailableTools,
+		"excludedTools":    config.ExcludedTools,
+		"streaming":        config.Streaming,
+		"provider":         config.Provider,
+		"mcpServers":       config.MCPServers,
+		"customAgents":     config.CustomAgents,
+		"skillDirectories": config.SkillDirectories,
+		"disabledSkills":   config.DisabledSkills,
+		"infiniteSessions": config.InfiniteSessions,
+	}
+}
+
+func toolMap(tools []Tool) map[string]Tool {
+	m := make(map[string]Tool, len(tools))
+	for _, t := range tools {
+		m[t.Name] = t
+	}
+	return m
+}
+
+// handleToolCallRequest dispatches a server-initiated tool call to the
+// handler registered for the session, returning a standardized failure
+// result if the tool isn't registered.
+func (c *Client) handleToolCallRequest(params map[string]interface{}) (map[string]interface{}, error) {
+	sessionID, _ := params["sessionId"].(string)
+	toolCallID, _ := params["toolCallId"].(string)
+	toolName, _ := params["toolName"].(string)
+	arguments := params["arguments"]
+
+	c.mu.Lock()
+	tools := c.sessionTools[sessionID]
+	c.mu.Unlock()
+
+	tool, ok := tools[toolName]
+	if !ok {
+		return map[string]interface{}{
+			"result": ToolResult{
+				ResultType: "failure",
+				Error:      fmt.Sprintf("tool '%s' not supported", toolName),
+			},
+		}, nil
+	}
+
+	result, err := tool.Handler(ToolInvocation{
+		SessionID:  sessionID,
+		ToolCallID: toolCallID,
+		ToolName:   toolName,
+		Arguments:  arguments,
+	})
+	if err != nil {
+		result.ResultType = "failure"
+		result.Error = err.Error()
+	}
+	return map[string]interface{}{"result": result}, nil
+}
+
+// ListSessions returns metadata for every session known to the connected
+// server, including sessions created by other clients.
+func (c *Client) ListSessions() (ListSessionsResponse, error) {
+	if err := c.ensureStarted(); err != nil {
+		return ListSessionsResponse{}, err
+	}
+	conn, err := c.activeConn()
+	if err != nil {
+		return ListSessionsResponse{}, err
+	}
+
+	var resp ListSessionsResponse
+	if err := conn.call("session.list", nil, &resp); err != nil {
+		return ListSessionsResponse{}, err
+	}
+	return resp, nil
+}
+
+// DeleteSession removes a session from the connected server. It does not
+// require the session to have been created by this Client.
+func (c *Client) DeleteSession(sessionID string) (DeleteSessionResponse, error) {
+	if err := c.ensureStarted(); err != nil {
+		return DeleteSessionResponse{}, err
+	}
+	conn, err := c.activeConn()
+	if err != nil {
+		return DeleteSessionResponse{}, err
+	}
+
+	var resp DeleteSessionResponse
+	if err := conn.call("session.delete", DeleteSessionRequest{SessionID: sessionID}, &resp); err != nil {
+		return DeleteSessionResponse{}, err
+	}
+
+	c.mu.Lock()
+	delete(c.sessionTools, sessionID)
+	delete(c.sessionConfigs, sessionID)
+	c.mu.Unlock()
+
+	return resp, nil
+}
+
+// Session represents an active Copilot conversation.
+type Session struct {
+	SessionID string
+
+	client *Client
+}
+
+// SendMessage sends a message on the session and returns the ID the server
+// assigned it. Use GetMessages, or a registered SessionEventHandler, to
+// observe the resulting events.
+func (s *Session) SendMessage(opts MessageOptions) (SessionSendResponse, error) {
+	conn, err := s.client.activeConn()
+	if err != nil {
+		return SessionSendResponse{}, err
+	}
+
+	var resp SessionSendResponse
+	params := map[string]interface{}{
+		"sessionId":   s.SessionID,
+		"prompt":      opts.Prompt,
+		"attachments": opts.Attachments,
+		"mode":        opts.Mode,
+	}
+	if err := conn.call("session.send", params, &resp); err != nil {
+		return SessionSendResponse{}, err
+	}
+	return resp, nil
+}
+
+// GetMessages returns the full event stream recorded for the session so far.
+func (s *Session) GetMessages() ([]SessionEvent, error) {
+	conn, err := s.client.activeConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var resp SessionGetMessagesResponse
+	params := map[string]interface{}{"sessionId": s.SessionID}
+	if err := conn.call("session.getMessages", params, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Events, nil
+}
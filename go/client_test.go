@@ -1,10 +1,19 @@
 package copilot
 
 import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // This file is for unit tests. Where relevant, prefer to add e2e tests in e2e/*.test.go instead
@@ -129,6 +138,13 @@ func TestClient_URLParsing(t *testing.T) {
 		})
 	})
 
+	t.Run("NewClientE returns ErrInvalidCLIUrl for invalid URL format", func(t *testing.T) {
+		_, err := NewClientE(&ClientOptions{CLIUrl: "invalid-url"})
+		if !errors.Is(err, ErrInvalidCLIUrl) {
+			t.Errorf("Expected errors.Is(err, ErrInvalidCLIUrl), got %v", err)
+		}
+	})
+
 	t.Run("should throw error for invalid port - too high", func(t *testing.T) {
 		defer func() {
 			if r := recover(); r == nil {
@@ -180,6 +196,15 @@ func TestClient_URLParsing(t *testing.T) {
 		})
 	})
 
+	t.Run("NewClientE returns ErrInvalidPort for out-of-range ports", func(t *testing.T) {
+		for _, url := range []string{"localhost:99999", "localhost:0", "localhost:-1"} {
+			_, err := NewClientE(&ClientOptions{CLIUrl: url})
+			if !errors.Is(err, ErrInvalidPort) {
+				t.Errorf("Expected errors.Is(err, ErrInvalidPort) for %q, got %v", url, err)
+			}
+		}
+	})
+
 	t.Run("should throw error when CLIUrl is used with UseStdio", func(t *testing.T) {
 		defer func() {
 			if r := recover(); r == nil {
@@ -216,6 +241,15 @@ func TestClient_URLParsing(t *testing.T) {
 		})
 	})
 
+	t.Run("NewClientE returns ErrConflictingOptions for CLIUrl with UseStdio or CLIPath", func(t *testing.T) {
+		if _, err := NewClientE(&ClientOptions{CLIUrl: "localhost:8080", UseStdio: true}); !errors.Is(err, ErrConflictingOptions) {
+			t.Errorf("Expected errors.Is(err, ErrConflictingOptions), got %v", err)
+		}
+		if _, err := NewClientE(&ClientOptions{CLIUrl: "localhost:8080", CLIPath: "/path/to/cli"}); !errors.Is(err, ErrConflictingOptions) {
+			t.Errorf("Expected errors.Is(err, ErrConflictingOptions), got %v", err)
+		}
+	})
+
 	t.Run("should set UseStdio to false when CLIUrl is provided", func(t *testing.T) {
 		client := NewClient(&ClientOptions{
 			CLIUrl: "8080",
@@ -235,6 +269,67 @@ func TestClient_URLParsing(t *testing.T) {
 			t.Error("Expected isExternalServer to be true when CLIUrl is provided")
 		}
 	})
+
+	t.Run("should parse unix:// socket URL format", func(t *testing.T) {
+		client := NewClient(&ClientOptions{
+			CLIUrl: "unix:///var/run/copilot.sock",
+		})
+
+		if client.actualSocket != "/var/run/copilot.sock" {
+			t.Errorf("Expected socket /var/run/copilot.sock, got %s", client.actualSocket)
+		}
+		if client.actualHost != "" || client.actualPort != 0 {
+			t.Errorf("Expected no host/port for unix socket, got %s:%d", client.actualHost, client.actualPort)
+		}
+		if !client.isExternalServer {
+			t.Error("Expected isExternalServer to be true")
+		}
+	})
+
+	t.Run("should parse plain unix:/path socket URL format", func(t *testing.T) {
+		client := NewClient(&ClientOptions{
+			CLIUrl: "unix:/tmp/copilot.sock",
+		})
+
+		if client.actualSocket != "/tmp/copilot.sock" {
+			t.Errorf("Expected socket /tmp/copilot.sock, got %s", client.actualSocket)
+		}
+	})
+
+	t.Run("should throw error for unix URL with missing path", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected panic for missing unix socket path")
+			} else {
+				matched, _ := regexp.MatchString("Invalid CLIUrl format", r.(string))
+				if !matched {
+					t.Errorf("Expected panic message to contain 'Invalid CLIUrl format', got: %v", r)
+				}
+			}
+		}()
+
+		NewClient(&ClientOptions{
+			CLIUrl: "unix://",
+		})
+	})
+
+	t.Run("should throw error when unix CLIUrl is used with UseStdio", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected panic for mutually exclusive options")
+			} else {
+				matched, _ := regexp.MatchString("CLIUrl is mutually exclusive", r.(string))
+				if !matched {
+					t.Errorf("Expected panic message to contain 'CLIUrl is mutually exclusive', got: %v", r)
+				}
+			}
+		}()
+
+		NewClient(&ClientOptions{
+			CLIUrl:   "unix:///var/run/copilot.sock",
+			UseStdio: true,
+		})
+	})
 }
 
 func TestClient_AuthOptions(t *testing.T) {
@@ -312,6 +407,119 @@ func TestClient_AuthOptions(t *testing.T) {
 			UseLoggedInUser: Bool(false),
 		})
 	})
+
+	t.Run("NewClientE returns ErrAuthWithExternalServer for auth options with CLIUrl", func(t *testing.T) {
+		if _, err := NewClientE(&ClientOptions{CLIUrl: "localhost:8080", GithubToken: "gho_test_token"}); !errors.Is(err, ErrAuthWithExternalServer) {
+			t.Errorf("Expected errors.Is(err, ErrAuthWithExternalServer), got %v", err)
+		}
+		if _, err := NewClientE(&ClientOptions{CLIUrl: "localhost:8080", UseLoggedInUser: Bool(false)}); !errors.Is(err, ErrAuthWithExternalServer) {
+			t.Errorf("Expected errors.Is(err, ErrAuthWithExternalServer), got %v", err)
+		}
+	})
+}
+
+func TestValidateOptions(t *testing.T) {
+	t.Run("returns nil for valid options", func(t *testing.T) {
+		if err := ValidateOptions(&ClientOptions{CLIUrl: "localhost:8080"}); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	})
+
+	t.Run("returns the same typed error as NewClientE without constructing a Client", func(t *testing.T) {
+		err := ValidateOptions(&ClientOptions{CLIUrl: "localhost:8080", GithubToken: "gho_test_token"})
+		if !errors.Is(err, ErrAuthWithExternalServer) {
+			t.Errorf("Expected errors.Is(err, ErrAuthWithExternalServer), got %v", err)
+		}
+	})
+
+	t.Run("performs no I/O for a Reconnect config, even against an unreachable server", func(t *testing.T) {
+		done := make(chan error, 1)
+		go func() {
+			done <- ValidateOptions(&ClientOptions{
+				CLIUrl:    "127.0.0.1:1", // nothing listens here
+				Reconnect: &ReconnectPolicy{},
+			})
+		}()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("ValidateOptions blocked on I/O instead of returning immediately")
+		}
+	})
+}
+
+func rpcSessionHandler(sessionID string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req jsonrpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := jsonrpcResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Result:  json.RawMessage(fmt.Sprintf(`{"sessionId":%q}`, sessionID)),
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func TestClient_HTTPTransport(t *testing.T) {
+	t.Run("round trips a session.create call over TLS with custom RootCAs", func(t *testing.T) {
+		server := httptest.NewTLSServer(rpcSessionHandler("sess-tls"))
+		defer server.Close()
+
+		pool := x509.NewCertPool()
+		pool.AddCert(server.Certificate())
+
+		client := NewClient(&ClientOptions{
+			CLIUrl:  server.URL,
+			RootCAs: pool,
+		})
+		t.Cleanup(func() { client.ForceStop() })
+
+		session, err := client.CreateSession(nil)
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		if session.SessionID != "sess-tls" {
+			t.Errorf("Expected sessionId sess-tls, got %s", session.SessionID)
+		}
+	})
+
+	t.Run("routes requests through the configured Proxy hook", func(t *testing.T) {
+		backend := httptest.NewServer(rpcSessionHandler("sess-proxy"))
+		defer backend.Close()
+		backendURL, err := url.Parse(backend.URL)
+		if err != nil {
+			t.Fatalf("failed to parse backend URL: %v", err)
+		}
+
+		var proxyCalls int32
+		client := NewClient(&ClientOptions{
+			CLIUrl: "http://copilot.invalid:80",
+			Proxy: func(req *http.Request) (*url.URL, error) {
+				atomic.AddInt32(&proxyCalls, 1)
+				return backendURL, nil
+			},
+		})
+		t.Cleanup(func() { client.ForceStop() })
+
+		session, err := client.CreateSession(nil)
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		if session.SessionID != "sess-proxy" {
+			t.Errorf("Expected sessionId sess-proxy, got %s", session.SessionID)
+		}
+		if atomic.LoadInt32(&proxyCalls) == 0 {
+			t.Error("Expected the Proxy hook to be called")
+		}
+	})
 }
 
 func findCLIPathForTest() string {
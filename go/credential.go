@@ -0,0 +1,338 @@
+package copilot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuthType identifies how a Token was obtained, surfaced so callers and logs
+// can distinguish a short-lived OAuth token from a long-lived PAT.
+type AuthType string
+
+const (
+	AuthTypePAT      AuthType = "pat"
+	AuthTypeOAuth    AuthType = "oauth"
+	AuthTypeGhCLI    AuthType = "gh-cli"
+	AuthTypeCallback AuthType = "callback"
+)
+
+// Token is a resolved credential, along with enough metadata for the client
+// to know when to refresh it.
+type Token struct {
+	Value     string
+	ExpiresAt time.Time // zero means the token does not expire
+	AuthType  AuthType
+}
+
+// Credential resolves a Token for authenticating with GitHub/Copilot.
+// Implementations should be safe for concurrent use.
+type Credential interface {
+	GetToken(ctx context.Context) (Token, error)
+}
+
+// StaticTokenCredential wraps a fixed token, e.g. a user-supplied PAT.
+type StaticTokenCredential struct {
+	Value string
+}
+
+func (c *StaticTokenCredential) GetToken(ctx context.Context) (Token, error) {
+	if c.Value == "" {
+		return Token{}, fmt.Errorf("copilot: StaticTokenCredential has no token")
+	}
+	return Token{Value: c.Value, AuthType: AuthTypePAT}, nil
+}
+
+// EnvCredential reads a token from the environment, checking GITHUB_TOKEN
+// then COPILOT_TOKEN.
+type EnvCredential struct{}
+
+func (c *EnvCredential) GetToken(ctx context.Context) (Token, error) {
+	for _, name := range []string{"GITHUB_TOKEN", "COPILOT_TOKEN"} {
+		if v := os.Getenv(name); v != "" {
+			return Token{Value: v, AuthType: AuthTypePAT}, nil
+		}
+	}
+	return Token{}, fmt.Errorf("copilot: neither GITHUB_TOKEN nor COPILOT_TOKEN is set")
+}
+
+// GhCLICredential resolves a token by shelling out to `gh auth token`.
+type GhCLICredential struct {
+	// GhPath overrides the `gh` executable looked up on PATH.
+	GhPath string
+}
+
+func (c *GhCLICredential) GetToken(ctx context.Context) (Token, error) {
+	ghPath := c.GhPath
+	if ghPath == "" {
+		ghPath = "gh"
+	}
+	cmd := exec.CommandContext(ctx, ghPath, "auth", "token")
+	out, err := cmd.Output()
+	if err != nil {
+		return Token{}, fmt.Errorf("copilot: gh auth token failed: %w", err)
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return Token{}, fmt.Errorf("copilot: gh auth token returned an empty token")
+	}
+	return Token{Value: token, AuthType: AuthTypeGhCLI}, nil
+}
+
+// CallbackCredential adapts a plain function to the Credential interface.
+type CallbackCredential func(ctx context.Context) (Token, error)
+
+func (c CallbackCredential) GetToken(ctx context.Context) (Token, error) {
+	return c(ctx)
+}
+
+// ChainedTokenCredential tries each Credential in order and returns the
+// first successful Token, remembering which provider succeeded so
+// subsequent calls try it first.
+type ChainedTokenCredential struct {
+	Providers []Credential
+
+	mu          sync.Mutex
+	hasLastGood bool
+	lastGoodIdx int // index into Providers of the last provider to succeed; meaningful only when hasLastGood
+}
+
+func (c *ChainedTokenCredential) GetToken(ctx context.Context) (Token, error) {
+	c.mu.Lock()
+	hasLastGood, lastGoodIdx := c.hasLastGood, c.lastGoodIdx
+	c.mu.Unlock()
+
+	if hasLastGood && lastGoodIdx < len(c.Providers) {
+		if tok, err := c.Providers[lastGoodIdx].GetToken(ctx); err == nil {
+			return tok, nil
+		}
+	}
+
+	var lastErr error
+	for i, p := range c.Providers {
+		if hasLastGood && i == lastGoodIdx {
+			continue
+		}
+		tok, err := p.GetToken(ctx)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		c.mu.Lock()
+		c.hasLastGood, c.lastGoodIdx = true, i
+		c.mu.Unlock()
+		return tok, nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("copilot: no credential providers configured")
+	}
+	return Token{}, fmt.Errorf("copilot: no credential in the chain produced a token: %w", lastErr)
+}
+
+// OAuthDeviceFlowCredential implements the GitHub OAuth device flow
+// (https://docs.github.com/en/apps/oauth-apps/building-oauth-apps/authorizing-oauth-apps#device-flow).
+// DeviceCodeURL/AccessTokenURL default to github.com's endpoints and are
+// overridable for testing. OnUserCode, if set, is invoked with the
+// verification URL and user code so the caller can display them.
+type OAuthDeviceFlowCredential struct {
+	ClientID       string
+	Scopes         []string
+	DeviceCodeURL  string
+	AccessTokenURL string
+	PollInterval   time.Duration
+	OnUserCode     func(verificationURI, userCode string)
+	HTTPClient     *http.Client
+}
+
+type deviceCodeResponse struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+type accessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	Error       string `json:"error"`
+}
+
+func (c *OAuthDeviceFlowCredential) GetToken(ctx context.Context) (Token, error) {
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	deviceCodeURL := c.DeviceCodeURL
+	if deviceCodeURL == "" {
+		deviceCodeURL = "https://github.com/login/device/code"
+	}
+	accessTokenURL := c.AccessTokenURL
+	if accessTokenURL == "" {
+		accessTokenURL = "https://github.com/login/oauth/access_token"
+	}
+
+	dc, err := c.requestDeviceCode(ctx, client, deviceCodeURL)
+	if err != nil {
+		return Token{}, err
+	}
+	if c.OnUserCode != nil {
+		c.OnUserCode(dc.VerificationURI, dc.UserCode)
+	}
+
+	interval := time.Duration(dc.Interval) * time.Second
+	if c.PollInterval > 0 {
+		interval = c.PollInterval
+	}
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(dc.ExpiresIn) * time.Second)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return Token{}, ctx.Err()
+		case <-time.After(interval):
+		}
+		if time.Now().After(deadline) {
+			return Token{}, fmt.Errorf("copilot: device code expired before authorization")
+		}
+
+		tok, pending, err := c.pollAccessToken(ctx, client, accessTokenURL, dc.DeviceCode)
+		if err != nil {
+			return Token{}, err
+		}
+		if pending {
+			continue
+		}
+		return tok, nil
+	}
+}
+
+func (c *OAuthDeviceFlowCredential) requestDeviceCode(ctx context.Context, client *http.Client, endpoint string) (*deviceCodeResponse, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"client_id": c.ClientID,
+		"scope":     strings.Join(c.Scopes, " "),
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("copilot: requesting device code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var dc deviceCodeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&dc); err != nil {
+		return nil, fmt.Errorf("copilot: decoding device code response: %w", err)
+	}
+	return &dc, nil
+}
+
+func (c *OAuthDeviceFlowCredential) pollAccessToken(ctx context.Context, client *http.Client, endpoint, deviceCode string) (Token, bool, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"client_id":   c.ClientID,
+		"device_code": deviceCode,
+		"grant_type":  "urn:ietf:params:oauth:grant-type:device_code",
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return Token{}, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Token{}, false, fmt.Errorf("copilot: polling access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var at accessTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&at); err != nil {
+		return Token{}, false, fmt.Errorf("copilot: decoding access token response: %w", err)
+	}
+
+	switch at.Error {
+	case "":
+		// success
+	case "authorization_pending", "slow_down":
+		return Token{}, true, nil
+	default:
+		return Token{}, false, fmt.Errorf("copilot: device flow authorization failed: %s", at.Error)
+	}
+
+	token := Token{Value: at.AccessToken, AuthType: AuthTypeOAuth}
+	if at.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(at.ExpiresIn) * time.Second)
+	}
+	return token, false, nil
+}
+
+// defaultCredentialChain builds the Credential a Client uses when
+// ClientOptions.Credential is unset, from the deprecated GithubToken and
+// UseLoggedInUser fields. This keeps existing code that sets those fields
+// compiling and behaving the same.
+func defaultCredentialChain(options *ClientOptions) Credential {
+	if options.GithubToken != "" {
+		static := &StaticTokenCredential{Value: options.GithubToken}
+		if options.UseLoggedInUser == nil || !*options.UseLoggedInUser {
+			return static
+		}
+		return &ChainedTokenCredential{Providers: []Credential{static, &EnvCredential{}, &GhCLICredential{}}}
+	}
+	if options.UseLoggedInUser != nil && !*options.UseLoggedInUser {
+		return &EnvCredential{}
+	}
+	return &ChainedTokenCredential{Providers: []Credential{&EnvCredential{}, &GhCLICredential{}}}
+}
+
+// defaultTokenRefreshSkew is how far ahead of a token's ExpiresAt the client
+// proactively re-resolves it.
+const defaultTokenRefreshSkew = 5 * time.Minute
+
+// resolveToken returns the client's current token, re-resolving it via the
+// credential chain if none is cached yet or the cached one is within its
+// refresh skew of expiring.
+func (c *Client) resolveToken(ctx context.Context) (Token, error) {
+	c.mu.Lock()
+	cred := c.credential
+	cached := c.cachedToken
+	c.mu.Unlock()
+
+	if cred == nil {
+		return Token{}, nil
+	}
+
+	skew := c.options.TokenRefreshSkew
+	if skew <= 0 {
+		skew = defaultTokenRefreshSkew
+	}
+	if cached.Value != "" && (cached.ExpiresAt.IsZero() || time.Until(cached.ExpiresAt) > skew) {
+		return cached, nil
+	}
+
+	tok, err := cred.GetToken(ctx)
+	if err != nil {
+		return Token{}, err
+	}
+
+	c.mu.Lock()
+	c.cachedToken = tok
+	c.mu.Unlock()
+	return tok, nil
+}
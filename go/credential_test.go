@@ -0,0 +1,217 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errTest = errors.New("test provider error")
+
+func TestStaticTokenCredential(t *testing.T) {
+	t.Run("returns the configured token", func(t *testing.T) {
+		cred := &StaticTokenCredential{Value: "gho_static"}
+		tok, err := cred.GetToken(context.Background())
+		if err != nil {
+			t.Fatalf("GetToken failed: %v", err)
+		}
+		if tok.Value != "gho_static" || tok.AuthType != AuthTypePAT {
+			t.Errorf("Unexpected token: %+v", tok)
+		}
+	})
+
+	t.Run("errors when empty", func(t *testing.T) {
+		if _, err := (&StaticTokenCredential{}).GetToken(context.Background()); err == nil {
+			t.Error("Expected error for empty StaticTokenCredential")
+		}
+	})
+}
+
+func TestEnvCredential(t *testing.T) {
+	t.Run("prefers GITHUB_TOKEN over COPILOT_TOKEN", func(t *testing.T) {
+		t.Setenv("GITHUB_TOKEN", "gho_env")
+		t.Setenv("COPILOT_TOKEN", "copilot_env")
+
+		tok, err := (&EnvCredential{}).GetToken(context.Background())
+		if err != nil {
+			t.Fatalf("GetToken failed: %v", err)
+		}
+		if tok.Value != "gho_env" {
+			t.Errorf("Expected gho_env, got %s", tok.Value)
+		}
+	})
+
+	t.Run("errors when neither variable is set", func(t *testing.T) {
+		t.Setenv("GITHUB_TOKEN", "")
+		t.Setenv("COPILOT_TOKEN", "")
+
+		if _, err := (&EnvCredential{}).GetToken(context.Background()); err == nil {
+			t.Error("Expected error when no token env var is set")
+		}
+	})
+}
+
+func TestChainedTokenCredential(t *testing.T) {
+	t.Run("returns the first successful provider's token", func(t *testing.T) {
+		failing := CallbackCredential(func(ctx context.Context) (Token, error) {
+			return Token{}, errTest
+		})
+		chain := &ChainedTokenCredential{
+			Providers: []Credential{failing, &StaticTokenCredential{Value: "gho_chain"}},
+		}
+
+		tok, err := chain.GetToken(context.Background())
+		if err != nil {
+			t.Fatalf("GetToken failed: %v", err)
+		}
+		if tok.Value != "gho_chain" {
+			t.Errorf("Expected gho_chain, got %s", tok.Value)
+		}
+	})
+
+	t.Run("caches the provider that last succeeded", func(t *testing.T) {
+		var calls int
+		tracked := CallbackCredential(func(ctx context.Context) (Token, error) {
+			calls++
+			return Token{Value: "gho_tracked"}, nil
+		})
+		neverCalled := CallbackCredential(func(ctx context.Context) (Token, error) {
+			t.Fatal("provider after the cached one should not be consulted")
+			return Token{}, nil
+		})
+		chain := &ChainedTokenCredential{Providers: []Credential{tracked, neverCalled}}
+
+		for i := 0; i < 3; i++ {
+			if _, err := chain.GetToken(context.Background()); err != nil {
+				t.Fatalf("GetToken failed: %v", err)
+			}
+		}
+		if calls != 3 {
+			t.Errorf("Expected the cached provider to be called 3 times, got %d", calls)
+		}
+	})
+
+	t.Run("returns an error when every provider fails", func(t *testing.T) {
+		chain := &ChainedTokenCredential{
+			Providers: []Credential{CallbackCredential(func(ctx context.Context) (Token, error) {
+				return Token{}, errTest
+			})},
+		}
+		if _, err := chain.GetToken(context.Background()); err == nil {
+			t.Error("Expected an error when all providers fail")
+		}
+	})
+
+	t.Run("falls through to the next provider when the cached one is a func and later fails", func(t *testing.T) {
+		// CallbackCredential's dynamic type is a func, which is uncomparable;
+		// comparing the cached provider by value (instead of by index) panics
+		// here once it starts failing.
+		var succeedOnce int32
+		flaky := CallbackCredential(func(ctx context.Context) (Token, error) {
+			if atomic.AddInt32(&succeedOnce, 1) == 1 {
+				return Token{Value: "gho_once"}, nil
+			}
+			return Token{}, errTest
+		})
+		chain := &ChainedTokenCredential{
+			Providers: []Credential{flaky, &StaticTokenCredential{Value: "gho_fallback"}},
+		}
+
+		if _, err := chain.GetToken(context.Background()); err != nil {
+			t.Fatalf("GetToken failed: %v", err)
+		}
+
+		tok, err := chain.GetToken(context.Background())
+		if err != nil {
+			t.Fatalf("GetToken failed on second call: %v", err)
+		}
+		if tok.Value != "gho_fallback" {
+			t.Errorf("Expected gho_fallback, got %s", tok.Value)
+		}
+	})
+}
+
+func TestOAuthDeviceFlowCredential(t *testing.T) {
+	t.Run("polls until authorization completes", func(t *testing.T) {
+		var polls int
+		mux := http.NewServeMux()
+		mux.HandleFunc("/device/code", func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode(deviceCodeResponse{
+				DeviceCode:      "dc-1",
+				UserCode:        "ABCD-1234",
+				VerificationURI: "https://example.com/activate",
+				ExpiresIn:       60,
+				Interval:        0,
+			})
+		})
+		mux.HandleFunc("/access_token", func(w http.ResponseWriter, r *http.Request) {
+			polls++
+			if polls < 2 {
+				_ = json.NewEncoder(w).Encode(accessTokenResponse{Error: "authorization_pending"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(accessTokenResponse{AccessToken: "gho_device", ExpiresIn: 3600})
+		})
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		var gotUserCode string
+		cred := &OAuthDeviceFlowCredential{
+			ClientID:       "test-client",
+			DeviceCodeURL:  server.URL + "/device/code",
+			AccessTokenURL: server.URL + "/access_token",
+			PollInterval:   time.Millisecond,
+			OnUserCode: func(verificationURI, userCode string) {
+				gotUserCode = userCode
+			},
+		}
+
+		tok, err := cred.GetToken(context.Background())
+		if err != nil {
+			t.Fatalf("GetToken failed: %v", err)
+		}
+		if tok.Value != "gho_device" || tok.AuthType != AuthTypeOAuth {
+			t.Errorf("Unexpected token: %+v", tok)
+		}
+		if tok.ExpiresAt.IsZero() {
+			t.Error("Expected ExpiresAt to be set")
+		}
+		if gotUserCode != "ABCD-1234" {
+			t.Errorf("Expected OnUserCode to report ABCD-1234, got %s", gotUserCode)
+		}
+		if polls < 2 {
+			t.Errorf("Expected at least 2 polls, got %d", polls)
+		}
+	})
+}
+
+func TestClient_DefaultCredentialChain(t *testing.T) {
+	t.Run("GithubToken alone maps to a StaticTokenCredential", func(t *testing.T) {
+		client := NewClient(&ClientOptions{GithubToken: "gho_test_token"})
+		if _, ok := client.credential.(*StaticTokenCredential); !ok {
+			t.Errorf("Expected *StaticTokenCredential, got %T", client.credential)
+		}
+	})
+
+	t.Run("explicit Credential takes priority over GithubToken shims", func(t *testing.T) {
+		cred := &StaticTokenCredential{Value: "gho_explicit"}
+		client := NewClient(&ClientOptions{Credential: cred, GithubToken: "gho_test_token"})
+		if client.credential != Credential(cred) {
+			t.Error("Expected the explicit Credential to be used as-is")
+		}
+	})
+
+	t.Run("throws error when Credential is used with CLIUrl", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected panic for Credential with CLIUrl")
+			}
+		}()
+		NewClient(&ClientOptions{CLIUrl: "localhost:8080", Credential: &StaticTokenCredential{Value: "x"}})
+	})
+}
@@ -0,0 +1,38 @@
+package copilot
+
+import "errors"
+
+// Sentinel errors for ClientOptions validation failures. Use errors.Is to
+// check for a specific category; use the *ConfigError itself for the Field
+// and Reason that triggered it.
+var (
+	// ErrInvalidCLIUrl indicates CLIUrl could not be parsed as a port,
+	// host:port, http(s):// URL, or unix:// socket path.
+	ErrInvalidCLIUrl = errors.New("invalid CLIUrl format")
+	// ErrInvalidPort indicates CLIUrl parsed to a port outside 1-65535.
+	ErrInvalidPort = errors.New("invalid port in CLIUrl")
+	// ErrConflictingOptions indicates CLIUrl was combined with CLIPath or
+	// UseStdio, which only apply to a client-managed CLI process.
+	ErrConflictingOptions = errors.New("CLIUrl is mutually exclusive with CLIPath and UseStdio")
+	// ErrAuthWithExternalServer indicates GithubToken, UseLoggedInUser, or
+	// Credential was combined with CLIUrl; authentication for an external server is the
+	// server operator's responsibility, not the client's.
+	ErrAuthWithExternalServer = errors.New("GithubToken and UseLoggedInUser cannot be used with CLIUrl")
+)
+
+// ConfigError describes an invalid ClientOptions value. Field names the
+// option that failed validation and Reason is a human-readable explanation;
+// use errors.Is(err, ErrInvalidCLIUrl) (etc.) to branch on the category.
+type ConfigError struct {
+	Field  string
+	Reason string
+	Err    error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Reason
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
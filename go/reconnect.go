@@ -0,0 +1,384 @@
+package copilot
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReconnectPolicy configures the supervised connection used for clients
+// connected to an external server (CLIUrl). A background goroutine probes
+// the server at HealthCheckInterval and reconnects with exponential backoff
+// when the probe fails. Calls in flight when the connection drops are held,
+// up to MaxRequeuedCalls, and replayed against the reconnected connection;
+// calls beyond that limit fail with a connection-closed error, and callers
+// should retry those themselves after the client's state returns to
+// StateConnected.
+type ReconnectPolicy struct {
+	// InitialBackoff is the delay before the first reconnect attempt.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between reconnect attempts.
+	MaxBackoff time.Duration
+	// Multiplier scales the backoff delay after each failed attempt.
+	Multiplier float64
+	// Jitter is a fraction (0.0-1.0) of the computed backoff to randomize,
+	// to avoid many clients retrying in lockstep.
+	Jitter float64
+	// MaxAttempts caps how many reconnect attempts are made after a single
+	// disconnect before the client transitions to StateFailed. 0 means retry
+	// indefinitely.
+	MaxAttempts int
+	// HealthCheckInterval is how often the supervisor probes the server.
+	HealthCheckInterval time.Duration
+	// MaxRequeuedCalls caps how many calls in flight when the connection
+	// drops are held and replayed against the reconnected connection. Calls
+	// beyond the limit fail immediately with a connection-closed error, the
+	// same as when the client has no Reconnect policy at all. Default: 64.
+	MaxRequeuedCalls int
+}
+
+// DefaultReconnectPolicy returns the policy NewClient uses when
+// ClientOptions.Reconnect is set to a zero-value *ReconnectPolicy (all
+// fields default to these values).
+func DefaultReconnectPolicy() *ReconnectPolicy {
+	return &ReconnectPolicy{
+		InitialBackoff:      500 * time.Millisecond,
+		MaxBackoff:          30 * time.Second,
+		Multiplier:          2,
+		Jitter:              0.2,
+		MaxAttempts:         0,
+		HealthCheckInterval: 5 * time.Second,
+		MaxRequeuedCalls:    64,
+	}
+}
+
+func (p *ReconnectPolicy) withDefaults() *ReconnectPolicy {
+	defaults := DefaultReconnectPolicy()
+	merged := *p
+	if merged.InitialBackoff <= 0 {
+		merged.InitialBackoff = defaults.InitialBackoff
+	}
+	if merged.MaxBackoff <= 0 {
+		merged.MaxBackoff = defaults.MaxBackoff
+	}
+	if merged.Multiplier <= 1 {
+		merged.Multiplier = defaults.Multiplier
+	}
+	if merged.Jitter == 0 {
+		merged.Jitter = defaults.Jitter
+	}
+	if merged.HealthCheckInterval <= 0 {
+		merged.HealthCheckInterval = defaults.HealthCheckInterval
+	}
+	if merged.MaxRequeuedCalls <= 0 {
+		merged.MaxRequeuedCalls = defaults.MaxRequeuedCalls
+	}
+	return &merged
+}
+
+// callRequeue is a supervisor's requeueSink: it holds up to limit calls that
+// were in flight when the supervised connection dropped, for reconnectLoop
+// to replay once a replacement connection is established.
+type callRequeue struct {
+	mu      sync.Mutex
+	limit   int
+	calls   []*pendingCall
+	stopped bool
+}
+
+func (r *callRequeue) accept(p *pendingCall) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped || len(r.calls) >= r.limit {
+		return false
+	}
+	r.calls = append(r.calls, p)
+	return true
+}
+
+func (r *callRequeue) drain() []*pendingCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	calls := r.calls
+	r.calls = nil
+	return calls
+}
+
+// close stops the sink from accepting further calls and fails any it's
+// already holding, e.g. when the supervisor is being torn down (Client
+// ForceStop) and nothing will ever reconnect to replay them against.
+// Without this, a call held here when that happens would otherwise sit
+// until its own timeout instead of failing immediately.
+func (r *callRequeue) close() {
+	r.mu.Lock()
+	r.stopped = true
+	calls := r.calls
+	r.calls = nil
+	r.mu.Unlock()
+
+	for _, p := range calls {
+		p.ch <- &jsonrpcResponse{Error: &jsonrpcError{Code: -1, Message: "connection closed"}}
+	}
+}
+
+// supervisor watches an external-server connection, reconnecting it per a
+// ReconnectPolicy and reporting state transitions via Client.OnStateChange.
+type supervisor struct {
+	client *Client
+	policy *ReconnectPolicy
+	// requeue holds calls in flight when the supervised connection drops,
+	// for replay once it's reconnected. Shared across every connection this
+	// supervisor dials (see Client.attachRequeueSink).
+	requeue *callRequeue
+
+	stateMu sync.RWMutex
+	state   ConnectionState
+	onState atomic.Value // func(old, new ConnectionState)
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newSupervisor(client *Client, policy *ReconnectPolicy) *supervisor {
+	merged := policy.withDefaults()
+	return &supervisor{
+		client:  client,
+		policy:  merged,
+		requeue: &callRequeue{limit: merged.MaxRequeuedCalls},
+		state:   StateConnected,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (s *supervisor) setState(next ConnectionState) {
+	s.stateMu.Lock()
+	prev := s.state
+	s.state = next
+	s.stateMu.Unlock()
+
+	if prev == next {
+		return
+	}
+	if handler, ok := s.onState.Load().(func(old, new ConnectionState)); ok && handler != nil {
+		handler(prev, next)
+	}
+}
+
+func (s *supervisor) currentState() ConnectionState {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	return s.state
+}
+
+// run is the supervisor's background loop. It ticks every
+// HealthCheckInterval, pinging the current connection; on failure it
+// reconnects with exponential backoff until MaxAttempts is exhausted (if
+// nonzero) or the connection is healthy again.
+func (s *supervisor) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.policy.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if s.probe() {
+				continue
+			}
+			if !s.reconnectLoop() {
+				return
+			}
+		}
+	}
+}
+
+// probe pings the server over the current connection, returning false if
+// the connection is unhealthy. Uses probeCall, not call, so a drop mid-probe
+// fails this immediately instead of sitting in the requeue sink: run's loop
+// is blocked on probe returning before it will dial a replacement connection
+// for anything to be replayed against.
+func (s *supervisor) probe() bool {
+	s.client.mu.Lock()
+	conn := s.client.conn
+	s.client.mu.Unlock()
+	if conn == nil {
+		return false
+	}
+	if rc, ok := conn.(*rpcConn); ok {
+		return rc.probeCall("ping") == nil
+	}
+	return conn.call("ping", nil, nil) == nil
+}
+
+// reconnectLoop retries dialing the server with exponential backoff until it
+// succeeds or MaxAttempts is exhausted. Returns false once the supervisor
+// should stop running (terminal failure).
+func (s *supervisor) reconnectLoop() bool {
+	s.setState(StateReconnecting)
+
+	backoff := s.policy.InitialBackoff
+	for attempt := 1; s.policy.MaxAttempts == 0 || attempt <= s.policy.MaxAttempts; attempt++ {
+		select {
+		case <-s.stop:
+			return false
+		case <-time.After(jitter(backoff, s.policy.Jitter)):
+		}
+
+		s.client.mu.Lock()
+		if s.client.conn != nil {
+			_ = s.client.conn.Close()
+			s.client.conn = nil
+		}
+		err := s.client.dialExternalLocked()
+		var conn rpcClient
+		if err == nil {
+			conn = s.client.conn
+		}
+		s.client.mu.Unlock()
+
+		// dialExternalLocked succeeding only means an http(s) transport was
+		// constructed (it connects lazily per-call) or a socket was opened;
+		// neither guarantees the server is actually responding. Ping it
+		// before declaring the reconnect a success, so a server that's
+		// merely listening but wedged doesn't short-circuit MaxAttempts.
+		if err == nil {
+			// probeCall for the same reason as in probe: this loop is what
+			// would replay a requeued ping, so it must not wait on one.
+			var perr error
+			if rc, ok := conn.(*rpcConn); ok {
+				perr = rc.probeCall("ping")
+			} else {
+				perr = conn.call("ping", nil, nil)
+			}
+			if perr != nil {
+				s.client.mu.Lock()
+				if s.client.conn == conn {
+					_ = conn.Close()
+					s.client.conn = nil
+				}
+				s.client.mu.Unlock()
+				err = perr
+			}
+		}
+
+		if err == nil {
+			s.setState(StateConnected)
+			s.replayRequeued(conn)
+			return true
+		}
+
+		backoff = time.Duration(float64(backoff) * s.policy.Multiplier)
+		if backoff > s.policy.MaxBackoff {
+			backoff = s.policy.MaxBackoff
+		}
+	}
+
+	s.setState(StateFailed)
+	// Nothing will dial again to replay these, so fail them now rather than
+	// leaving callers to wait out their own call timeout.
+	s.requeue.close()
+	return false
+}
+
+// replayRequeued resends every call that was in flight on the connection
+// that just died, against the newly (re)established conn, delivering each
+// response to the original pendingCall.ch — which the caller's still-blocked
+// rpcConn.call is waiting on. Each replay runs in its own goroutine so one
+// slow or hung call can't hold up the others.
+func (s *supervisor) replayRequeued(conn rpcClient) {
+	rc, ok := conn.(*rpcConn)
+	if !ok {
+		return
+	}
+	for _, p := range s.requeue.drain() {
+		go func(p *pendingCall) {
+			resp, err := rc.roundTrip(p.method, p.params, false)
+			if err != nil {
+				resp = &jsonrpcResponse{Error: &jsonrpcError{Code: -1, Message: err.Error()}}
+			}
+			p.ch <- resp
+		}(p)
+	}
+}
+
+func jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return d
+	}
+	delta := float64(d) * fraction
+	return d + time.Duration((rand.Float64()*2-1)*delta)
+}
+
+func (s *supervisor) Stop() {
+	close(s.stop)
+	<-s.done
+	s.requeue.close()
+}
+
+// OnStateChange registers a callback invoked whenever the supervised
+// connection's state changes. Only meaningful for clients created with
+// ClientOptions.Reconnect set; it is a no-op otherwise. Only one handler is
+// kept at a time; calling it again replaces the previous handler.
+func (c *Client) OnStateChange(handler func(old, new ConnectionState)) {
+	c.mu.Lock()
+	sup := c.supervisor
+	c.mu.Unlock()
+	if sup != nil {
+		sup.onState.Store(handler)
+	}
+}
+
+// State returns the client's current supervised connection state. Clients
+// without ClientOptions.Reconnect always report StateConnected once
+// connected.
+func (c *Client) State() ConnectionState {
+	c.mu.Lock()
+	sup := c.supervisor
+	c.mu.Unlock()
+	if sup == nil {
+		return StateConnected
+	}
+	return sup.currentState()
+}
+
+// dialExternalLocked (re)establishes c.conn for an external server. The
+// caller must hold c.mu.
+func (c *Client) dialExternalLocked() error {
+	if c.actualSocket != "" {
+		conn, err := dialUnix(c.actualSocket)
+		if err != nil {
+			return err
+		}
+		c.attachRequeueSink(conn)
+		c.conn = conn
+		return nil
+	}
+	if c.actualScheme == "http" || c.actualScheme == "https" {
+		c.conn = newHTTPTransport(c.actualScheme, c.actualHost, c.actualPort, c.options)
+		return nil
+	}
+	conn, err := dialTCP(c.actualHost, c.actualPort)
+	if err != nil {
+		return err
+	}
+	c.attachRequeueSink(conn)
+	c.conn = conn
+	return nil
+}
+
+// attachRequeueSink wires conn so that, if it's supervised and later drops,
+// calls in flight get handed to the supervisor's requeue sink instead of
+// failing outright (see ReconnectPolicy.MaxRequeuedCalls). A no-op when the
+// client has no supervisor, i.e. Reconnect wasn't configured. The caller
+// must hold c.mu.
+func (c *Client) attachRequeueSink(conn *rpcConn) {
+	if c.supervisor == nil {
+		return
+	}
+	conn.requeueSink = c.supervisor.requeue
+}
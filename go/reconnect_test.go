@@ -0,0 +1,257 @@
+package copilot
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeRPCServer accepts TCP connections and answers jsonrpcRequests with a
+// canned response until told to drop the next connection.
+type fakeRPCServer struct {
+	listener net.Listener
+	drop     int32 // connections accepted after this counter is nonzero are closed immediately
+
+	mu          sync.Mutex
+	holdAndDrop string // method name; the next request matching it drops its connection unanswered
+}
+
+func newFakeRPCServer(t *testing.T) *fakeRPCServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &fakeRPCServer{listener: ln}
+	go s.acceptLoop()
+	return s
+}
+
+func (s *fakeRPCServer) acceptLoop() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		if atomic.LoadInt32(&s.drop) != 0 {
+			conn.Close()
+			continue
+		}
+		go s.serve(conn)
+	}
+}
+
+func (s *fakeRPCServer) serve(conn net.Conn) {
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+	for {
+		var req jsonrpcRequest
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		if atomic.LoadInt32(&s.drop) != 0 {
+			return
+		}
+		if s.takeHoldAndDrop(req.Method) {
+			return
+		}
+		resp := jsonrpcResponse{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage(`{}`)}
+		if req.Method == "session.create" {
+			resp.Result = json.RawMessage(`{"sessionId":"sess-reconnect"}`)
+		}
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// holdAndDropOnce arranges for the next request for method to be decoded and
+// then left unanswered, dropping its connection — simulating a call that was
+// genuinely in flight when the connection died.
+func (s *fakeRPCServer) holdAndDropOnce(method string) {
+	s.mu.Lock()
+	s.holdAndDrop = method
+	s.mu.Unlock()
+}
+
+// takeHoldAndDrop reports whether method matches a pending holdAndDropOnce
+// request, consuming it so only that one request is affected.
+func (s *fakeRPCServer) takeHoldAndDrop(method string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.holdAndDrop == "" || s.holdAndDrop != method {
+		return false
+	}
+	s.holdAndDrop = ""
+	return true
+}
+
+func (s *fakeRPCServer) dropConnections() { atomic.StoreInt32(&s.drop, 1) }
+func (s *fakeRPCServer) accept()          { atomic.StoreInt32(&s.drop, 0) }
+func (s *fakeRPCServer) addr() string     { return s.listener.Addr().String() }
+func (s *fakeRPCServer) Close()           { s.listener.Close() }
+
+func TestClient_Reconnect(t *testing.T) {
+	t.Run("resumes transparently after the connection drops", func(t *testing.T) {
+		server := newFakeRPCServer(t)
+		defer server.Close()
+
+		var states []ConnectionState
+		client, err := NewClientE(&ClientOptions{
+			CLIUrl: server.addr(),
+			Reconnect: &ReconnectPolicy{
+				InitialBackoff:      10 * time.Millisecond,
+				MaxBackoff:          20 * time.Millisecond,
+				Multiplier:          2,
+				HealthCheckInterval: 20 * time.Millisecond,
+			},
+		})
+		if err != nil {
+			t.Fatalf("NewClientE failed: %v", err)
+		}
+		t.Cleanup(client.ForceStop)
+		client.OnStateChange(func(old, new ConnectionState) {
+			states = append(states, new)
+		})
+
+		server.dropConnections()
+		deadline := time.Now().Add(2 * time.Second)
+		for client.State() != StateReconnecting && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		server.accept()
+		deadline = time.Now().Add(2 * time.Second)
+		for client.State() != StateConnected && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		if client.State() != StateConnected {
+			t.Fatalf("Expected client to resume StateConnected, got %s (history: %v)", client.State(), states)
+		}
+
+		session, err := client.CreateSession(nil)
+		if err != nil {
+			t.Fatalf("CreateSession after reconnect failed: %v", err)
+		}
+		if session.SessionID != "sess-reconnect" {
+			t.Errorf("Expected sessionId sess-reconnect, got %s", session.SessionID)
+		}
+	})
+
+	t.Run("replays a call that was in flight when the connection dropped", func(t *testing.T) {
+		server := newFakeRPCServer(t)
+		defer server.Close()
+
+		client, err := NewClientE(&ClientOptions{
+			CLIUrl: server.addr(),
+			Reconnect: &ReconnectPolicy{
+				InitialBackoff:      10 * time.Millisecond,
+				MaxBackoff:          20 * time.Millisecond,
+				Multiplier:          2,
+				HealthCheckInterval: 20 * time.Millisecond,
+			},
+		})
+		if err != nil {
+			t.Fatalf("NewClientE failed: %v", err)
+		}
+		t.Cleanup(client.ForceStop)
+
+		session, err := client.CreateSession(nil)
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+
+		server.holdAndDropOnce("session.send")
+
+		type sendResult struct {
+			resp SessionSendResponse
+			err  error
+		}
+		done := make(chan sendResult, 1)
+		go func() {
+			resp, err := session.SendMessage(MessageOptions{Prompt: "still there?"})
+			done <- sendResult{resp, err}
+		}()
+
+		select {
+		case r := <-done:
+			if r.err != nil {
+				t.Fatalf("SendMessage failed: %v", r.err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("SendMessage never returned: the in-flight call was not requeued and replayed")
+		}
+	})
+
+	t.Run("surfaces StateFailed once MaxAttempts is exhausted", func(t *testing.T) {
+		server := newFakeRPCServer(t)
+		server.Close() // nothing is listening; every reconnect attempt fails immediately
+
+		client, err := NewClientE(&ClientOptions{
+			CLIUrl: server.addr(),
+		})
+		if err != nil {
+			t.Fatalf("NewClientE failed: %v", err)
+		}
+		t.Cleanup(client.ForceStop)
+
+		client.supervisor = newSupervisor(client, &ReconnectPolicy{
+			InitialBackoff:      1 * time.Millisecond,
+			MaxBackoff:          2 * time.Millisecond,
+			Multiplier:          2,
+			MaxAttempts:         3,
+			HealthCheckInterval: 5 * time.Millisecond,
+		})
+		go client.supervisor.run()
+
+		deadline := time.Now().Add(2 * time.Second)
+		for client.State() != StateFailed && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		if client.State() != StateFailed {
+			t.Fatalf("Expected client to reach StateFailed, got %s", client.State())
+		}
+	})
+
+	t.Run("surfaces StateFailed for an http(s) external server that stops responding", func(t *testing.T) {
+		// newHTTPTransport never dials eagerly, so dialExternalLocked alone
+		// would report success even though nothing is listening; the
+		// supervisor must ping before declaring StateConnected.
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		server.Close() // closed before first use: every request will fail to connect
+
+		client, err := NewClientE(&ClientOptions{CLIUrl: server.URL})
+		if err != nil {
+			t.Fatalf("NewClientE failed: %v", err)
+		}
+		t.Cleanup(client.ForceStop)
+
+		client.supervisor = newSupervisor(client, &ReconnectPolicy{
+			InitialBackoff:      1 * time.Millisecond,
+			MaxBackoff:          2 * time.Millisecond,
+			Multiplier:          2,
+			MaxAttempts:         3,
+			HealthCheckInterval: 5 * time.Millisecond,
+		})
+		go client.supervisor.run()
+
+		deadline := time.Now().Add(2 * time.Second)
+		for client.State() != StateFailed && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		if client.State() != StateFailed {
+			t.Fatalf("Expected client to reach StateFailed, got %s", client.State())
+		}
+	})
+}
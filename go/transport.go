@@ -0,0 +1,322 @@
+package copilot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rpcClient is satisfied by every transport (stdio/TCP/unix socket framing,
+// or request/response over HTTP) that can make JSON-RPC calls to a Copilot
+// CLI server.
+type rpcClient interface {
+	call(method string, params interface{}, result interface{}) error
+	Close() error
+}
+
+// jsonrpcRequest is a JSON-RPC 2.0 request sent to the CLI server.
+type jsonrpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      int64       `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// jsonrpcResponse is a JSON-RPC 2.0 response received from the CLI server.
+type jsonrpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int64           `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonrpcError   `json:"error,omitempty"`
+}
+
+type jsonrpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *jsonrpcError) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// defaultCallTimeout bounds how long rpcConn.call waits for a response. It
+// is a backstop for a response that never arrives while the connection stays
+// open (e.g. a server that stops answering without closing it); without it,
+// that call would block forever since nothing would deliver to its channel.
+const defaultCallTimeout = 30 * time.Second
+
+// pendingCall is an in-flight request: enough to both wait for its response
+// (ch) and, if the connection dies before one arrives, replay it on a
+// replacement connection (method, params).
+type pendingCall struct {
+	method string
+	params interface{}
+	ch     chan *jsonrpcResponse
+	// noRequeue marks a call the supervisor's own health check made, which
+	// must fail fast on disconnect rather than sit in the requeue sink: the
+	// supervisor's run loop is itself blocked on this call returning before
+	// it will dial a replacement connection to replay anything against.
+	noRequeue bool
+}
+
+// requeueSink receives the calls that were in flight when a connection died,
+// so a supervisor can replay them against the replacement connection instead
+// of failing them outright. accept reports whether it took ownership of the
+// call; returning false (e.g. over capacity) leaves it to fail normally with
+// a connection-closed error. nil means no supervisor is attached, so every
+// pending call fails immediately.
+type requeueSink interface {
+	accept(p *pendingCall) bool
+}
+
+// rpcConn wraps a transport connection with newline-delimited JSON-RPC framing.
+type rpcConn struct {
+	rwc         io.ReadWriteCloser
+	cmd         *exec.Cmd
+	writer      *bufio.Writer
+	reader      *bufio.Scanner
+	callTimeout time.Duration
+	// requeueSink, set by the reconnect supervisor, is consulted by
+	// failPending before erroring out a pending call. Unset (nil) for
+	// connections outside a supervised reconnect.
+	requeueSink requeueSink
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]*pendingCall
+	closed  bool
+}
+
+func newRPCConn(rwc io.ReadWriteCloser, cmd *exec.Cmd) *rpcConn {
+	c := &rpcConn{
+		rwc:         rwc,
+		cmd:         cmd,
+		writer:      bufio.NewWriter(rwc),
+		reader:      bufio.NewScanner(rwc),
+		callTimeout: defaultCallTimeout,
+		pending:     make(map[int64]*pendingCall),
+	}
+	c.reader.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	go c.readLoop()
+	return c
+}
+
+func (c *rpcConn) readLoop() {
+	for c.reader.Scan() {
+		var resp jsonrpcResponse
+		if err := json.Unmarshal(c.reader.Bytes(), &resp); err != nil {
+			continue
+		}
+		c.mu.Lock()
+		p, ok := c.pending[resp.ID]
+		if ok {
+			delete(c.pending, resp.ID)
+		}
+		c.mu.Unlock()
+		if ok {
+			p.ch <- &resp
+		}
+	}
+	c.failPending()
+}
+
+// failPending marks the connection closed and resolves every in-flight call:
+// handed off to requeueSink if one is attached and has room, otherwise failed
+// with a connection-closed error. This runs e.g. after the underlying
+// connection drops mid-read. Marking closed under the same lock used by
+// call's registration step ensures a call that hasn't registered its pending
+// entry yet will observe closed and fail fast instead of registering into a
+// pending map nothing will ever drain again.
+func (c *rpcConn) failPending() {
+	c.mu.Lock()
+	c.closed = true
+	pending := c.pending
+	c.pending = make(map[int64]*pendingCall)
+	sink := c.requeueSink
+	c.mu.Unlock()
+
+	for _, p := range pending {
+		if !p.noRequeue && sink != nil && sink.accept(p) {
+			continue
+		}
+		p.ch <- &jsonrpcResponse{Error: &jsonrpcError{Code: -1, Message: "connection closed"}}
+	}
+}
+
+func (c *rpcConn) call(method string, params interface{}, result interface{}) error {
+	resp, err := c.roundTrip(method, params, false)
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result != nil && resp.Result != nil {
+		return json.Unmarshal(resp.Result, result)
+	}
+	return nil
+}
+
+// probeCall is like call but marks the request noRequeue: used for the
+// supervisor's own health-check ping, which must fail fast on disconnect so
+// the supervisor's run loop can move on to reconnecting instead of blocking
+// on a requeued reply that only reconnecting itself would ever produce.
+func (c *rpcConn) probeCall(method string) error {
+	resp, err := c.roundTrip(method, nil, true)
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return nil
+}
+
+// roundTrip sends a request and waits for its raw response, without
+// unmarshaling Result into a caller-provided type. It is call's core, and
+// also how a requeued call (replayed by a supervisor against a replacement
+// connection, with no result pointer of its own) gets its response: the
+// response is pushed onto the original pendingCall.ch, which the original,
+// still-blocked call() is waiting on.
+func (c *rpcConn) roundTrip(method string, params interface{}, noRequeue bool) (*jsonrpcResponse, error) {
+	id := atomic.AddInt64(&c.nextID, 1)
+	ch := make(chan *jsonrpcResponse, 1)
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("jsonrpc: connection closed")
+	}
+	c.pending[id] = &pendingCall{method: method, params: params, ch: ch, noRequeue: noRequeue}
+	c.mu.Unlock()
+
+	req := jsonrpcRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params}
+	data, err := json.Marshal(req)
+	if err != nil {
+		c.removePending(id)
+		return nil, err
+	}
+
+	c.mu.Lock()
+	_, werr := c.writer.Write(append(data, '\n'))
+	if werr == nil {
+		werr = c.writer.Flush()
+	}
+	c.mu.Unlock()
+	if werr != nil {
+		c.removePending(id)
+		return nil, werr
+	}
+
+	timeout := c.callTimeout
+	if timeout <= 0 {
+		timeout = defaultCallTimeout
+	}
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-time.After(timeout):
+		c.removePending(id)
+		return nil, fmt.Errorf("jsonrpc: call %q timed out after %s", method, timeout)
+	}
+}
+
+// removePending discards a call's pending entry, e.g. after it times out or
+// fails to send, so a late response (or failPending) doesn't write to a
+// channel nobody is still reading from.
+func (c *rpcConn) removePending(id int64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+func (c *rpcConn) Close() error {
+	err := c.rwc.Close()
+	if c.cmd != nil && c.cmd.Process != nil {
+		_ = c.cmd.Process.Kill()
+	}
+	return err
+}
+
+// dialTCP connects to a Copilot CLI server listening on host:port over TCP.
+func dialTCP(host string, port int) (*rpcConn, error) {
+	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%d", host, port))
+	if err != nil {
+		return nil, err
+	}
+	return newRPCConn(conn, nil), nil
+}
+
+// dialUnix connects to a Copilot CLI server listening on a Unix domain socket.
+func dialUnix(path string) (*rpcConn, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return newRPCConn(conn, nil), nil
+}
+
+// httpTransport issues JSON-RPC calls as individual HTTP POST requests,
+// used when CLIUrl points at an http:// or https:// endpoint.
+type httpTransport struct {
+	client   *http.Client
+	endpoint string
+	nextID   int64
+}
+
+// newHTTPTransport builds an httpTransport honoring the Proxy and TLS
+// options configured on ClientOptions for an external http(s) server.
+func newHTTPTransport(scheme, host string, port int, options *ClientOptions) *httpTransport {
+	proxy := options.Proxy
+	if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+	return &httpTransport{
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:           proxy,
+				TLSClientConfig: buildTLSConfig(options),
+			},
+		},
+		endpoint: fmt.Sprintf("%s://%s:%d/rpc", scheme, host, port),
+	}
+}
+
+func (h *httpTransport) call(method string, params interface{}, result interface{}) error {
+	id := atomic.AddInt64(&h.nextID, 1)
+	body, err := json.Marshal(jsonrpcRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+
+	resp, err := h.client.Post(h.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var rpcResp jsonrpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return err
+	}
+	if rpcResp.Error != nil {
+		return rpcResp.Error
+	}
+	if result != nil && rpcResp.Result != nil {
+		return json.Unmarshal(rpcResp.Result, result)
+	}
+	return nil
+}
+
+func (h *httpTransport) Close() error {
+	h.client.CloseIdleConnections()
+	return nil
+}
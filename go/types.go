@@ -1,5 +1,13 @@
 package copilot
 
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"time"
+)
+
 // ConnectionState represents the client connection state
 type ConnectionState string
 
@@ -8,6 +16,13 @@ const (
 	StateConnecting   ConnectionState = "connecting"
 	StateConnected    ConnectionState = "connected"
 	StateError        ConnectionState = "error"
+	// StateReconnecting means a previously established connection to an
+	// external server was lost and the supervisor is retrying per
+	// ReconnectPolicy.
+	StateReconnecting ConnectionState = "reconnecting"
+	// StateFailed means the supervisor exhausted ReconnectPolicy.MaxAttempts
+	// without reconnecting; the client will not retry further.
+	StateFailed ConnectionState = "failed"
 )
 
 // ClientOptions configures the CopilotClient
@@ -20,9 +35,11 @@ type ClientOptions struct {
 	Port int
 	// UseStdio enables stdio transport instead of TCP (default: true)
 	UseStdio bool
-	// CLIUrl is the URL of an existing Copilot CLI server to connect to over TCP
-	// Format: "host:port", "http://host:port", or just "port" (defaults to localhost)
-	// Examples: "localhost:8080", "http://127.0.0.1:9000", "8080"
+	// CLIUrl is the URL of an existing Copilot CLI server to connect to, over
+	// TCP or a Unix domain socket.
+	// Format: "host:port", "http://host:port", "port" (defaults to localhost),
+	// or "unix:///path/to.sock" (also accepts the shorter "unix:/path" form).
+	// Examples: "localhost:8080", "http://127.0.0.1:9000", "8080", "unix:///var/run/copilot.sock"
 	// Mutually exclusive with CLIPath, UseStdio
 	CLIUrl string
 	// LogLevel for the CLI server
@@ -45,6 +62,44 @@ type ClientOptions struct {
 	// Default: true (but defaults to false when GithubToken is provided).
 	// Use Bool(false) to explicitly disable.
 	UseLoggedInUser *bool
+	// Credential supplies the authentication token for a client-managed CLI
+	// process via a pluggable provider chain, superseding GithubToken and
+	// UseLoggedInUser when set. See Credential, ChainedTokenCredential, and
+	// the Static/Env/GhCLI/OAuthDeviceFlow/Callback credential providers.
+	// When nil, GithubToken and UseLoggedInUser are used to construct an
+	// equivalent chain.
+	Credential Credential
+	// TokenRefreshSkew controls how far ahead of a Token's ExpiresAt the
+	// client proactively re-resolves it via Credential. Default: 5 minutes.
+	TokenRefreshSkew time.Duration
+	// Proxy resolves the HTTP(S) proxy to use for external-server connections
+	// made over an http:// or https:// CLIUrl. It has the same signature as
+	// http.Transport.Proxy. When nil, http.ProxyFromEnvironment is used,
+	// honoring HTTP_PROXY/HTTPS_PROXY/NO_PROXY.
+	Proxy func(*http.Request) (*url.URL, error)
+	// TLSConfig overrides the TLS configuration used for an https:// CLIUrl.
+	// When it is set, RootCAs and ClientCertificates fill in a copy of this
+	// config only where it leaves RootCAs or Certificates unset.
+	TLSConfig *tls.Config
+	// RootCAs is a convenience for trusting a custom certificate authority
+	// without constructing a full TLSConfig.
+	RootCAs *x509.CertPool
+	// ClientCertificates configures mutual TLS client certificates for an
+	// https:// CLIUrl.
+	ClientCertificates []tls.Certificate
+	// Reconnect enables a supervised connection to an external server
+	// (CLIUrl): a background health check detects dropped connections and
+	// transparently reconnects per the policy. Nil disables reconnection.
+	Reconnect *ReconnectPolicy
+	// ArchiveStore is used by ArchiveSession, SearchArchivedSessions,
+	// ExportArchive, ImportArchive, and ReplaySession to persist and query
+	// session archives. When nil, a FilesystemArchiveStore rooted at
+	// ArchiveDir is used.
+	ArchiveStore ArchiveStore
+	// ArchiveDir is the directory a default FilesystemArchiveStore writes
+	// to when ArchiveStore is unset. Default: "copilot-archives" under Cwd
+	// (or the process's working directory if Cwd is unset).
+	ArchiveDir string
 }
 
 // Bool returns a pointer to the given bool value.
@@ -296,6 +351,26 @@ type MessageOptions struct {
 	Mode string
 }
 
+// Attachment references a file or directory included alongside a message's
+// Prompt.
+type Attachment struct {
+	// Path is the absolute or workspace-relative path to attach.
+	Path string `json:"path"`
+	// Type is "file" or "directory". Defaults to "file".
+	Type string `json:"type,omitempty"`
+}
+
+// SessionEvent represents a single event emitted during a session: an
+// assistant message or reasoning chunk, a tool invocation and its result, a
+// permission request and decision, etc. Kind distinguishes the event type;
+// Data carries kind-specific fields.
+type SessionEvent struct {
+	Kind      string                 `json:"kind"`
+	SessionID string                 `json:"sessionId,omitempty"`
+	Timestamp string                 `json:"timestamp,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
 // SessionEventHandler is a callback for session events
 type SessionEventHandler func(event SessionEvent)
 